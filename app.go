@@ -1,8 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	termbox "github.com/nsf/termbox-go"
 	"github.com/taylorskalyo/goreader/epub"
+	"github.com/taylorskalyo/goreader/render"
+	"github.com/taylorskalyo/goreader/source"
 )
 
 // app is used to store the current state of the application.
@@ -10,6 +16,92 @@ type app struct {
 	pager   pager
 	book    *epub.Rootfile
 	chapter int
+
+	// src is the open book, read from the file called name, which is at index
+	// current of the queue of files given on the command line.
+	src     *source.Book
+	name    string
+	queue   []string
+	current int
+
+	// settings controls how the book is displayed. Books open with the
+	// global settings in config, overridden by their saved settings and then
+	// by split and themeName, when they are set on the command line. theme
+	// is the theme the settings name.
+	settings  settings
+	config    config
+	split     *int
+	themeName *string
+	theme     render.Theme
+
+	// state is persisted between sessions under key.
+	state *state
+	key   string
+
+	// stats, when set, logs the current reading session.
+	stats   *statsLogger
+	session session
+
+	// menu, when set, is displayed over the pager.
+	menu *menu
+
+	// dimmed records whether the night shift currently applies, and wake,
+	// when set, wakes the event loop when that next changes.
+	dimmed bool
+	wake   *time.Timer
+
+	// furthest is the furthest position in the book that has been read to.
+	furthest position
+
+	// anchor is where the text at the top of the viewport was put by the
+	// last reflow (see reflowAnchor).
+	anchor anchor
+
+	// adjusted is when a key press last changed the layout, and pending
+	// records that the text is yet to be reflowed for it, once
+	// adjustTimer goes off (see adjustLayout).
+	adjusted    time.Time
+	pending     bool
+	adjustTimer *time.Timer
+
+	// prefix is a press of [ or ] that may be the first of two, made at
+	// prefixed; prefixTimer wakes the event loop once the second is no
+	// longer waited for (see bracketKey).
+	prefix      rune
+	prefixed    time.Time
+	prefixTimer *time.Timer
+
+	// details records, by chapter, the <details> elements that have been
+	// expanded or collapsed, by their index in the chapter's document.
+	details map[int]map[int]bool
+
+	// lineOffsets, when line numbers continue through the book, holds the
+	// number of rows before the start of each chapter, for as many chapters
+	// as have been counted since the last reflow.
+	lineOffsets []int
+
+	// text caches the plain text of the book's chapters.
+	text *bookText
+
+	// tooltip is the title last shown by showTooltip.
+	tooltip render.Tooltip
+
+	// history holds the positions that links were followed from, most
+	// recent last (see followLink), and visited what the links followed
+	// point to (see linkKey).
+	history []position
+	visited map[string]bool
+
+	// query is the text being searched for, if any (see search).
+	query string
+
+	// message, when set, is shown in the status bar until the next key
+	// press.
+	message string
+
+	// interstitial, when set, is shown in place of the pager until the next
+	// key press (see transition).
+	interstitial string
 }
 
 // run opens a book, renders its contents within the pager, and polls for
@@ -21,102 +113,227 @@ func (a *app) run() error {
 	defer termbox.Flush()
 	defer termbox.Close()
 
-	if err := a.openChapter(); err != nil {
+	// Images drawn in color use the 256-color palette. Termbox's 24-bit
+	// output mode is not used even where the terminal supports it, since
+	// it cannot draw in the terminal's default colors, which themes rely on.
+	if a.config.colorImages() {
+		termbox.SetOutputMode(termbox.Output256)
+	}
+
+	a.checkNight()
+	if err := a.reflow(); err != nil {
 		return err
 	}
+	a.startSession()
+	defer a.endSession()
+	defer a.savePosition()
 
+	defer a.stopWake()
+	defer a.stopAdjust()
+	defer a.stopPrefix()
+
+	// The loop blocks until an event arrives, and only redraws for those that
+	// may change what is shown. Keys run the actions they are bound to (see
+	// keyBindings).
+	keys := keyMap()
+	redraw := true
 	for {
-		if err := a.pager.draw(); err != nil {
-			return err
+		if redraw {
+			if err := a.flushLayout(); err != nil {
+				return err
+			}
+			if err := a.expirePrefix(); err != nil {
+				return err
+			}
+			if a.settings.Paged {
+				a.pager.alignPage()
+			}
+			a.advanceFurthest()
+			if err := a.draw(); err != nil {
+				return err
+			}
+			a.scheduleWake()
 		}
-		switch ev := termbox.PollEvent(); ev.Type {
+
+		ev := termbox.PollEvent()
+		redraw = ev.Type == termbox.EventKey || ev.Type == termbox.EventResize || ev.Type == termbox.EventInterrupt
+		switch ev.Type {
+		case termbox.EventError:
+			return ev.Err
+		case termbox.EventResize:
+			if err := a.resize(); err != nil {
+				return err
+			}
 		case termbox.EventKey:
-			switch ev.Key {
-			case termbox.KeyEsc:
-				return nil
-			case termbox.KeyArrowDown:
-				a.pager.scrollDown()
-			case termbox.KeyArrowUp:
-				a.pager.scrollUp()
-			case termbox.KeyArrowRight:
-				a.pager.scrollRight()
-			case termbox.KeyArrowLeft:
-				a.pager.scrollLeft()
-			default:
-				switch ev.Ch {
-				case 'q':
-					return nil
-				case 'j':
-					a.pager.scrollDown()
-				case 'k':
-					a.pager.scrollUp()
-				case 'h':
-					a.pager.scrollLeft()
-				case 'l':
-					a.pager.scrollRight()
-				case 'f':
-					if a.pager.pageDown() || a.chapter >= len(a.book.Spine.Itemrefs)-1 {
-						continue
-					}
-
-					// Go to the next chapter if we reached the end.
-					if err := a.nextChapter(); err != nil {
-						return err
-					}
-					a.pager.toTop()
-				case 'b':
-					if a.pager.pageUp() || a.chapter <= 0 {
-						continue
-					}
-
-					// Go to the previous chapter if we reached the beginning.
-					if err := a.prevChapter(); err != nil {
-						return err
-					}
-					a.pager.toBottom()
-				case 'g':
-					a.pager.toTop()
-				case 'G':
-					a.pager.toBottom()
-				case 'L':
-					if a.chapter >= len(a.book.Spine.Itemrefs)-1 {
-						continue
-					}
-
-					if err := a.nextChapter(); err != nil {
-						return err
-					}
-					a.pager.toTop()
-				case 'H':
-					if a.chapter <= 0 {
-						continue
-					}
-
-					if err := a.prevChapter(); err != nil {
-						return err
-					}
-					a.pager.toTop()
+			a.message = ""
+			if a.interstitial != "" {
+				a.interstitial = ""
+				continue
+			}
+			if a.prefix != 0 && ev.Ch != a.prefix {
+				if err := a.flushPrefix(); err != nil {
+					return err
 				}
 			}
+			key := keyName(ev)
+			b, ok := keys[key]
+			if !ok {
+				continue
+			}
+			err := b.run(a, key)
+			if err == errQuit {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
 		}
 	}
 }
 
-// openChapter opens the current chapter and renders it within the pager.
-func (a *app) openChapter() error {
-	f, err := a.book.Spine.Itemrefs[a.chapter].Open()
-	if err != nil {
-		return err
+// scheduleWake arranges for the event loop to be woken when the night shift
+// next starts or ends, so that the display is dimmed or restored without
+// waiting for a key press. Without a night shift, nothing wakes the loop.
+func (a *app) scheduleWake() {
+	d, ok := a.config.Night.untilChange(time.Now())
+	if !ok {
+		return
+	}
+
+	a.stopWake()
+	a.wake = time.AfterFunc(d, termbox.Interrupt)
+}
+
+// stopWake cancels the wake up arranged by scheduleWake, if any.
+func (a *app) stopWake() {
+	if a.wake != nil {
+		a.wake.Stop()
+		a.wake = nil
+	}
+}
+
+// draw displays the pager and status bar in the terminal.
+func (a *app) draw() error {
+	if a.checkNight() && a.settings.Images && a.config.Night.Brightness != 0 {
+		if err := a.reflow(); err != nil {
+			return err
+		}
+	}
+
+	termbox.Clear(termbox.ColorDefault, a.theme.Background)
+	if tooSmall() {
+		width, _ := termbox.Size()
+		printText(0, 0, width, "Terminal too small", termbox.ColorDefault, termbox.ColorDefault)
+		return termbox.Flush()
+	}
+	if a.interstitial != "" {
+		a.drawInterstitial()
+	} else {
+		a.pager.draw()
+		if a.pager.doc.Rows() == 0 {
+			drawBlank()
+		}
+	}
+	if a.message != "" {
+		drawStatus(a.message, "")
+	} else {
+		drawStatus(a.status())
+	}
+	if a.menu != nil {
+		a.menu.draw()
 	}
-	doc, err := parseText(f, a.book.Manifest.Items)
+
+	return termbox.Flush()
+}
+
+// blankMarker is shown in place of the content of chapters that have none.
+const blankMarker = "[blank page]"
+
+// drawBlank displays blankMarker in the middle of the pager's viewport, so that
+// a blank chapter is not mistaken for a failure to display it.
+func drawBlank() {
+	width, height := viewSize()
+	x := max((width-len(blankMarker))/2, 0)
+	printText(x, height/2, width, blankMarker, termbox.ColorDefault|termbox.AttrDim, termbox.ColorDefault)
+}
+
+// openChapter opens the current chapter and renders it within the pager,
+// leaving a gutter for line numbers when they are shown.
+func (a *app) openChapter() error {
+	doc, opts, first, err := a.layoutChapter(a.chapter)
 	if err != nil {
 		return err
 	}
 	a.pager.doc = doc
+	a.pager.gutter, a.pager.firstLine = opts.Gutter, first
+	a.pager.focus = a.settings.Focus
+	a.pager.lead = nil
+	a.pager.link = 0
+	a.markVisited()
+	a.pager.matches, a.pager.match = doc.Find(a.query), 0
 
 	return nil
 }
 
+// layoutChapter lays out the chapter at index i of the spine as it is
+// displayed: when line numbers are shown, in what the terminal leaves beside
+// a gutter wide enough for them. It returns the options it was laid out with
+// and the number of its first row.
+func (a *app) layoutChapter(i int) (render.Document, render.Options, int, error) {
+	opts := a.renderOptions()
+	first := 1
+	if a.settings.LineNumbers {
+		first += a.lineOffset(i)
+		opts.Gutter = gutterWidth(first)
+		fitTerminal(&opts)
+	}
+	doc, err := a.parseChapter(i, opts)
+	if err != nil {
+		return doc, opts, first, err
+	}
+
+	// Chapters with too many rows for the gutter are laid out again in a
+	// wider one.
+	if last := first + doc.Rows() - 1; opts.Gutter > 0 && gutterWidth(last) > opts.Gutter {
+		opts.Gutter = gutterWidth(last)
+		fitTerminal(&opts)
+		doc, err = a.parseChapter(i, opts)
+	}
+
+	return doc, opts, first, err
+}
+
+// lineOffset returns the number of rows in the book before the chapter at
+// index i when line numbers continue from one chapter to the next, laying out
+// the chapters before it as they are displayed as needed, and zero otherwise.
+// Chapters that cannot be laid out are counted as having no rows.
+func (a *app) lineOffset(i int) int {
+	if a.config.LineNumbering != lineNumbersContinuous {
+		return 0
+	}
+	if len(a.lineOffsets) == 0 {
+		a.lineOffsets = []int{0}
+	}
+	for n := len(a.lineOffsets) - 1; n < i; n++ {
+		doc, _, _, _ := a.layoutChapter(n)
+		a.lineOffsets = append(a.lineOffsets, a.lineOffsets[n]+doc.Rows())
+	}
+
+	return a.lineOffsets[i]
+}
+
+// parseChapter renders the chapter at index i of the spine.
+func (a *app) parseChapter(i int, opts render.Options) (render.Document, error) {
+	f, err := a.book.Spine.Itemrefs[i].Open()
+	if err != nil {
+		return render.Document{}, err
+	}
+	defer f.Close()
+
+	opts.Details = a.details[i]
+	return render.Parse(f, a.book.Manifest.Items, opts)
+}
+
 // nextChapter opens the next chapter.
 func (a *app) nextChapter() error {
 	a.chapter++
@@ -128,3 +345,510 @@ func (a *app) prevChapter() error {
 	a.chapter--
 	return a.openChapter()
 }
+
+// toLandmark sets the current chapter to the one containing the first
+// landmark of one of the given types. It returns false if there is no such
+// landmark in the spine.
+func (a *app) toLandmark(types ...string) bool {
+	l, ok := a.book.Landmark(types...)
+	if !ok {
+		return false
+	}
+
+	i, ok := a.book.SpineIndex(l.HREF)
+	if !ok {
+		return false
+	}
+	a.chapter = i
+
+	return true
+}
+
+// landmarkMenu lets the reader choose one of the book's landmarks and opens
+// the chapter containing it.
+func (a *app) landmarkMenu() error {
+	var landmarks []epub.Landmark
+	var entries []string
+	for _, l := range a.book.Landmarks {
+		if _, ok := a.book.SpineIndex(l.HREF); !ok {
+			continue
+		}
+
+		title := l.Title
+		if title == "" {
+			title = l.Type
+		}
+		landmarks = append(landmarks, l)
+		entries = append(entries, title)
+	}
+
+	i, err := a.runMenu(&menu{title: "Landmarks", entries: entries})
+	if err != nil || i < 0 {
+		return err
+	}
+
+	a.chapter, _ = a.book.SpineIndex(landmarks[i].HREF)
+	if err := a.openChapter(); err != nil {
+		return err
+	}
+	a.pager.toTop()
+
+	return nil
+}
+
+// contentsMenu lets the reader choose an entry from the book's table of
+// contents, or a chapter if it has none, and opens the chapter containing it.
+// The entry for the current chapter is highlighted.
+func (a *app) contentsMenu() error {
+	var chapters []int
+	m := &menu{title: "Contents", filterable: true, markCurrent: true}
+	for _, e := range a.book.TOC {
+		i, ok := a.book.SpineIndex(e.HREF)
+		if !ok {
+			continue
+		}
+		if i <= a.chapter {
+			m.current = len(chapters)
+		}
+		chapters = append(chapters, i)
+		m.entries = append(m.entries, strings.Repeat("  ", e.Depth)+e.Title)
+	}
+	if len(chapters) == 0 {
+		for i := range a.book.Spine.Itemrefs {
+			chapters = append(chapters, i)
+			m.entries = append(m.entries, fmt.Sprintf("Chapter %d", i+1))
+		}
+		m.current = a.chapter
+	}
+	m.selected = m.current
+
+	i, err := a.runMenu(m)
+	if err != nil || i < 0 {
+		return err
+	}
+
+	a.chapter = chapters[i]
+	if err := a.openChapter(); err != nil {
+		return err
+	}
+	a.pager.toTop()
+
+	return nil
+}
+
+// pageMenu lets the reader choose one of the print edition's pages, and
+// scrolls to where it starts.
+func (a *app) pageMenu() error {
+	type pageRef struct {
+		chapter, index int
+	}
+
+	// Pages are listed without rendering images, which only changes where
+	// they start, not which chapter they are in.
+	opts := a.renderOptions()
+	opts.Images = false
+	var refs []pageRef
+	m := &menu{title: "Pages"}
+	for i := range a.book.Spine.Itemrefs {
+		doc, err := a.parseChapter(i, opts)
+		if err != nil {
+			return err
+		}
+		for j, pg := range doc.Pages {
+			if i == a.chapter && pg.Row <= a.pager.scrollY {
+				m.selected = len(refs)
+			}
+			refs = append(refs, pageRef{i, j})
+			m.entries = append(m.entries, "Page "+pg.Label)
+		}
+	}
+	m.move(0)
+
+	i, err := a.runMenu(m)
+	if err != nil || i < 0 {
+		return err
+	}
+
+	a.chapter = refs[i].chapter
+	if err := a.openChapter(); err != nil {
+		return err
+	}
+	a.pager.toTop()
+	if pages := a.pager.doc.Pages; refs[i].index < len(pages) {
+		a.pager.toRow(pages[refs[i].index].Row)
+	}
+
+	return nil
+}
+
+// reload re-opens the book from disk, keeping the reading position where it
+// is still valid. The outcome is reported in the status bar.
+func (a *app) reload() {
+	if a.name == source.StdinName {
+		a.message = "Cannot reload a book read from stdin"
+		return
+	}
+
+	b, err := openBook(a.name)
+	if err != nil {
+		a.message = fmt.Sprintf("Unable to reload book: %s", err)
+		return
+	}
+
+	a.src.Close()
+	a.src, a.book = b, b.Rootfile
+	a.text = newBookText(a.book, a.name, a.key)
+	if a.chapter >= len(a.book.Spine.Itemrefs) {
+		a.chapter = len(a.book.Spine.Itemrefs) - 1
+		a.pager.doc = render.Document{}
+		a.anchor = anchor{}
+		a.pager.toTop()
+	}
+	if err := a.reflow(); err != nil {
+		a.message = fmt.Sprintf("Unable to reload chapter: %s", err)
+		return
+	}
+	a.message = "Reloaded " + a.name
+}
+
+// advanceFurthest moves the furthest read position up to the current one, if
+// it is further into the book.
+func (a *app) advanceFurthest() {
+	if pos := a.here(); pos.after(a.furthest) {
+		a.furthest = pos
+	}
+}
+
+// toFurthest returns to the furthest position in the book that has been read
+// to, found by its source offset as the reading position is (see
+// toPosition).
+func (a *app) toFurthest() error {
+	// The book may have lost chapters since it was reloaded.
+	if last := len(a.book.Spine.Itemrefs) - 1; a.furthest.Chapter > last {
+		a.furthest = position{Chapter: last}
+	}
+
+	return a.toPosition(a.furthest)
+}
+
+// sectionForward moves to the next section of the chapter, or failing that to
+// the start of the next chapter.
+func (a *app) sectionForward() error {
+	if a.pager.nextSection() || a.chapter >= len(a.book.Spine.Itemrefs)-1 {
+		return nil
+	}
+
+	prev := a.pager.doc
+	if err := a.nextChapter(); err != nil {
+		return err
+	}
+	a.pager.toTop()
+	a.transition(prev, true)
+
+	return nil
+}
+
+// sectionBack moves to the previous section of the chapter, or failing that
+// to the last section of the previous chapter.
+func (a *app) sectionBack() error {
+	if a.pager.prevSection() || a.chapter <= 0 {
+		return nil
+	}
+
+	prev := a.pager.doc
+	if err := a.prevChapter(); err != nil {
+		return err
+	}
+	if !a.pager.toLastSection() {
+		a.pager.toTop()
+	}
+	a.transition(prev, false)
+
+	return nil
+}
+
+// pageForward scrolls down a page, going on to the next chapter at the end of
+// the current one.
+func (a *app) pageForward() error {
+	if a.pager.pageDown() {
+		return nil
+	}
+
+	return a.flowForward()
+}
+
+// pageBack scrolls up a page, going back to the end of the previous chapter
+// at the start of the current one.
+func (a *app) pageBack() error {
+	if a.pager.pageUp() {
+		return nil
+	}
+
+	return a.flowBack()
+}
+
+// scrollDown scrolls down a line or, in paged view, a page. Unless
+// scroll_chapters is disabled, scrolling past the end of a chapter continues
+// into the next.
+func (a *app) scrollDown() error {
+	if a.settings.Paged {
+		return a.pageForward()
+	}
+	if a.pager.scrollDown() || !a.config.ScrollChapters {
+		return nil
+	}
+
+	return a.flowForward()
+}
+
+// scrollUp scrolls up a line or, in paged view, a page. Unless
+// scroll_chapters is disabled, scrolling past the start of a chapter continues
+// from the end of the previous one.
+func (a *app) scrollUp() error {
+	if a.settings.Paged {
+		return a.pageBack()
+	}
+	if a.pager.scrollUp() || !a.config.ScrollChapters {
+		return nil
+	}
+
+	return a.flowBack()
+}
+
+// flowForward goes on to the start of the chapter after the current one in
+// the book's reading order, if there is one. Blank chapters are passed over
+// when blank_chapters is "skip", unless there are none after them.
+func (a *app) flowForward() error {
+	prev := a.pager.doc
+	for {
+		i, ok := a.linearChapter(1)
+		if !ok {
+			return nil
+		}
+
+		a.chapter = i
+		if err := a.openChapter(); err != nil {
+			return err
+		}
+		a.pager.toTop()
+		if !a.skipBlank() {
+			a.transition(prev, true)
+			return nil
+		}
+	}
+}
+
+// flowBack goes back to the end of the chapter before the current one in the
+// book's reading order, if there is one. Blank chapters are passed over as for
+// flowForward.
+func (a *app) flowBack() error {
+	prev := a.pager.doc
+	for {
+		i, ok := a.linearChapter(-1)
+		if !ok {
+			return nil
+		}
+
+		a.chapter = i
+		if err := a.openChapter(); err != nil {
+			return err
+		}
+		a.pager.toBottom()
+		if !a.skipBlank() {
+			a.transition(prev, false)
+			return nil
+		}
+	}
+}
+
+// skipBlank reports whether the current chapter has no visible content and
+// should be passed over when moving through the book.
+func (a *app) skipBlank() bool {
+	return a.config.BlankChapters == blankSkip && a.pager.doc.Rows() == 0
+}
+
+// linearChapter returns the index of the nearest chapter after the current
+// one, for a positive step, or before it, for a negative step, that is part
+// of the book's reading order; chapters marked non-linear (e.g. notes) are
+// skipped. It returns false if there is none.
+func (a *app) linearChapter(step int) (int, bool) {
+	itemrefs := a.book.Spine.Itemrefs
+	for i := a.chapter + step; i >= 0 && i < len(itemrefs); i += step {
+		if itemrefs[i].Linear != "no" {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// toggleDetails expands or collapses the first <details> element whose
+// summary is within the pager's viewport, keeping the summary where it was on
+// screen.
+func (a *app) toggleDetails() error {
+	_, viewHeight := viewSize()
+	for i, d := range a.pager.doc.Details {
+		if d.Row < a.pager.scrollY || d.Row >= a.pager.scrollY+viewHeight {
+			continue
+		}
+
+		if a.details == nil {
+			a.details = map[int]map[int]bool{}
+		}
+		if a.details[a.chapter] == nil {
+			a.details[a.chapter] = map[int]bool{}
+		}
+		a.details[a.chapter][i] = !d.Open
+		offset := d.Row - a.pager.scrollY
+		if err := a.openChapter(); err != nil {
+			return err
+		}
+		if i < len(a.pager.doc.Details) {
+			a.pager.toRow(a.pager.doc.Details[i].Row - offset)
+		}
+		return nil
+	}
+
+	a.message = "No collapsible section on screen"
+	return nil
+}
+
+// showTooltip shows the title of an inline element within the pager's
+// viewport, such as the expansion of an abbreviation, in the status bar. Each
+// press shows the next on screen after the one last shown.
+func (a *app) showTooltip() {
+	_, viewHeight := viewSize()
+	var next *render.Tooltip
+	last := false
+	for i, t := range a.pager.doc.Tooltips {
+		if t.End < a.pager.scrollY || t.Row >= a.pager.scrollY+viewHeight {
+			continue
+		}
+		if next == nil || last {
+			next = &a.pager.doc.Tooltips[i]
+			if last {
+				break
+			}
+		}
+		last = t == a.tooltip
+	}
+	if next == nil {
+		a.message = "No abbreviation or title on screen"
+		return
+	}
+
+	a.tooltip = *next
+	a.message = next.Text + ": " + next.Title
+}
+
+// reflow re-renders the current chapter after a change in settings, keeping
+// the text at the top of the viewport in view, and the viewport within the new
+// document's boundaries.
+func (a *app) reflow() error {
+	offset, anchored := a.reflowAnchor()
+	a.lineOffsets = nil
+	if err := a.openChapter(); err != nil {
+		return err
+	}
+	if anchored {
+		a.pager.toRow(a.pager.doc.Row(offset))
+		a.anchor = anchor{chapter: a.chapter, row: a.pager.scrollY, offset: offset, set: true}
+	} else if a.pager.scrollY > a.pager.maxScrollY() {
+		a.pager.toBottom()
+	}
+
+	return nil
+}
+
+// anchor records the position in a chapter's source of the text that a
+// reflow kept at the top of the viewport, and the row it was put on.
+type anchor struct {
+	chapter, row, offset int
+	set                  bool
+}
+
+// reflowAnchor returns the position in the current chapter's source of the
+// text at the top of the viewport. The text is found by the row it is on, so
+// the position of the last reflow is reused until the viewport moves; this
+// keeps repeated reflows from drifting back to the start of each new line. It
+// returns false if there is no text in view.
+func (a *app) reflowAnchor() (int, bool) {
+	if a.anchor.set && a.anchor.chapter == a.chapter && a.anchor.row == a.pager.scrollY {
+		return a.anchor.offset, true
+	}
+
+	return a.pager.doc.Offset(a.pager.scrollY)
+}
+
+// checkNight dims or restores the display according to the night shift
+// schedule. It returns true if the display changed.
+func (a *app) checkNight() bool {
+	dim := a.config.Night.active(time.Now())
+	if dim == a.dimmed {
+		return false
+	}
+	a.dimmed = dim
+	a.pager.dim = dim
+
+	return true
+}
+
+// renderOptions returns the options the book is laid out with, narrowed to
+// fit the terminal, with images darkened while the night shift is active.
+func (a *app) renderOptions() render.Options {
+	opts := a.settings.renderOptions()
+	opts.Theme = &a.theme
+	opts.NoColor = a.config.NoColor
+	if a.config.colorImages() {
+		opts.ImageMode = render.ImageBlocks
+	}
+	fitTerminal(&opts)
+	if a.dimmed {
+		opts.Brightness = clamp(opts.Brightness+a.config.Night.Brightness, -maxImageLevel, maxImageLevel)
+	}
+
+	return opts
+}
+
+// adjustLevel changes an image level by step, keeping it within
+// [-maxImageLevel, maxImageLevel]. It returns false if the level is unchanged.
+func adjustLevel(level *int, step int) bool {
+	v := clamp(*level+step, -maxImageLevel, maxImageLevel)
+	if v == *level {
+		return false
+	}
+	*level = v
+
+	return true
+}
+
+// adjustImage raises an image level by a step, or lowers it if up is false,
+// and lays the images out again if they are shown.
+func (a *app) adjustImage(level *int, up bool) error {
+	step := imageLevelStep
+	if !up {
+		step = -imageLevelStep
+	}
+	if !adjustLevel(level, step) || !a.settings.Images {
+		return nil
+	}
+
+	return a.reflow()
+}
+
+// saveSettings stores the current settings that differ from the global ones
+// as overrides for this book, and saves them at once. Failing to save is
+// reported in the status bar.
+func (a *app) saveSettings() {
+	if a.name == source.StdinName {
+		a.message = "Cannot save settings for a book read from stdin"
+		return
+	}
+
+	a.state.book(a.key).Settings = a.settings.overrides(a.config.settings)
+	if err := a.state.save(); err != nil {
+		a.message = fmt.Sprintf("Unable to save settings: %s", err)
+		return
+	}
+	a.message = "Saved settings for this book"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/taylorskalyo/goreader/source"
+)
+
+// bookmarkPrompt precedes the name of a bookmark being typed in the status
+// bar.
+const bookmarkPrompt = "Bookmark name: "
+
+// here returns the reading position: the chapter, and the row and source
+// offset of the text at the top of the viewport.
+func (a *app) here() position {
+	pos := position{Chapter: a.chapter, Row: a.pager.scrollY}
+	if offset, ok := a.reflowAnchor(); ok {
+		pos.Offset = offset
+	}
+
+	return pos
+}
+
+// inBook reports whether pos is in a chapter of the current book. Saved
+// positions may not be, if the book has changed or the state file has been
+// edited.
+func (a *app) inBook(pos position) bool {
+	return pos.Chapter >= 0 && pos.Chapter < len(a.book.Spine.Itemrefs)
+}
+
+// toPosition opens the chapter of pos and scrolls to the text at its source
+// offset, or failing that to its row.
+func (a *app) toPosition(pos position) error {
+	if a.chapter != pos.Chapter {
+		a.chapter = pos.Chapter
+		if err := a.openChapter(); err != nil {
+			return err
+		}
+	}
+	if pos.Offset > 0 {
+		a.pager.toRow(a.pager.doc.Row(pos.Offset))
+		a.anchor = anchor{chapter: a.chapter, row: a.pager.scrollY, offset: pos.Offset, set: true}
+	} else {
+		a.pager.toRow(pos.Row)
+	}
+
+	return nil
+}
+
+// addBookmark bookmarks the reading position under a name typed in the status
+// bar, or the title of the chapter if none is given, and saves it at once.
+// Failing to save is reported in the status bar.
+func (a *app) addBookmark() error {
+	if a.name == source.StdinName {
+		a.message = "Cannot bookmark a book read from stdin"
+		return nil
+	}
+
+	pos := a.here()
+	name, ok, err := a.readCommand(bookmarkPrompt)
+	if err != nil || !ok {
+		return err
+	}
+	if name == "" {
+		name = a.title()
+	}
+
+	a.state.book(a.key).setBookmark(bookmark{Name: name, position: pos})
+	if err := a.state.save(); err != nil {
+		a.message = fmt.Sprintf("Unable to save bookmark: %s", err)
+		return nil
+	}
+	a.message = "Bookmarked " + name
+
+	return nil
+}
+
+// bookmarkMenu lets the reader choose one of the book's bookmarks and goes to
+// it.
+func (a *app) bookmarkMenu() error {
+	m := &menu{title: "Bookmarks", filterable: true}
+	var shown []bookmark
+	if bs := a.state.Books[a.key]; bs != nil {
+		for _, b := range bs.Bookmarks {
+			if !a.inBook(b.position) {
+				continue
+			}
+			shown = append(shown, b)
+			m.entries = append(m.entries, fmt.Sprintf("%s (chapter %d)", b.Name, b.Chapter+1))
+		}
+	}
+	if len(shown) == 0 {
+		a.message = "No bookmarks; press m to add one"
+		return nil
+	}
+
+	i, err := a.runMenu(m)
+	if err != nil || i < 0 {
+		return err
+	}
+
+	return a.toPosition(shown[i].position)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/taylorskalyo/goreader/epub"
+	"github.com/taylorskalyo/goreader/render"
+	"github.com/taylorskalyo/goreader/source"
+)
+
+// maxCachedText is the most plain text, in bytes, that a bookText holds at
+// once.
+const maxCachedText = 16 << 20
+
+// textCacheVersion is changed whenever the text extracted from chapters
+// changes, so that text cached on disk by earlier versions is extracted
+// again.
+const textCacheVersion = 1
+
+// bookText caches the plain text of a book's chapters, for features that need
+// its words but not how they are laid out, such as the reading time left in
+// the book. Since the text does not depend on the display settings, it is
+// extracted once for each book rather than on every reflow. To bound the
+// memory used by very large books, at most maxCachedText bytes of text are
+// held, dropping the chapters used longest ago first; their word counts are
+// kept.
+//
+// The text is also cached on disk, so that it is not extracted again in the
+// next session, e.g. the first time the book is searched (see openCache).
+type bookText struct {
+	book *epub.Rootfile
+
+	// name is the file the book was opened from and key the key its state
+	// is stored under (see bookKey). dir is the directory its text is
+	// cached in on disk, or empty if it is not, once opened is set.
+	name, key string
+	dir       string
+	opened    bool
+
+	// text holds the text of chapters by their index in the spine, and used
+	// their indexes, from the least recently used. size is the length of
+	// the text held.
+	text map[int]string
+	used []int
+	size int
+
+	// words holds the number of words in each chapter that has been
+	// extracted.
+	words map[int]int
+}
+
+// newBookText returns an empty cache of the text of book, which was opened
+// from the file called name and has its state stored under key.
+func newBookText(book *epub.Rootfile, name, key string) *bookText {
+	return &bookText{book: book, name: name, key: key, text: map[int]string{}, words: map[int]int{}}
+}
+
+// chapter returns the plain text of the chapter at index i of the spine,
+// extracting it if it is not held. Lines of text are trimmed and blank lines
+// left out.
+func (t *bookText) chapter(i int) (string, error) {
+	if s, ok := t.text[i]; ok {
+		t.use(i)
+		return s, nil
+	}
+
+	s, ok := t.cached(i)
+	if !ok {
+		var err error
+		if s, err = extractText(t.book, i); err != nil {
+			return "", err
+		}
+		t.store(i, s)
+	}
+	t.words[i] = len(strings.Fields(s))
+	t.text[i] = s
+	t.size += len(s)
+	t.use(i)
+	for t.size > maxCachedText && len(t.used) > 1 {
+		t.size -= len(t.text[t.used[0]])
+		delete(t.text, t.used[0])
+		t.used = t.used[1:]
+	}
+
+	return s, nil
+}
+
+// use marks the chapter at index i as the most recently used.
+func (t *bookText) use(i int) {
+	if j := slices.Index(t.used, i); j >= 0 {
+		t.used = slices.Delete(t.used, j, j+1)
+	}
+	t.used = append(t.used, i)
+}
+
+// wordCount returns the number of words in the chapter at index i of the
+// spine. Chapters that cannot be read have none.
+func (t *bookText) wordCount(i int) int {
+	if n, ok := t.words[i]; ok {
+		return n
+	}
+	if _, err := t.chapter(i); err != nil {
+		return 0
+	}
+
+	return t.words[i]
+}
+
+// openCache finds the directory the book's text is cached in on disk, named
+// after its key, the first time it is called. The directory records the hash
+// of the book's file that its text was extracted from; when the file has
+// changed since, the text cached is removed. Books read from stdin are not
+// cached. Failures leave the text uncached, since the cache only saves time.
+func (t *bookText) openCache() {
+	if t.opened {
+		return
+	}
+	t.opened = true
+	if t.name == "" || t.name == source.StdinName {
+		return
+	}
+
+	hash, err := fileHash(t.name)
+	if err != nil {
+		return
+	}
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return
+	}
+	dir := filepath.Join(base, "goreader", "text", fmt.Sprintf("%x", sha256.Sum256([]byte(t.key))))
+	stamp := fmt.Sprintf("%d %s\n", textCacheVersion, hash)
+	path := filepath.Join(dir, "book")
+	if b, err := os.ReadFile(path); err != nil || string(b) != stamp {
+		os.RemoveAll(dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return
+		}
+		if err := os.WriteFile(path, []byte(stamp), 0644); err != nil {
+			return
+		}
+	}
+	t.dir = dir
+}
+
+// cached returns the text of the chapter at index i of the spine cached on
+// disk, if there is any.
+func (t *bookText) cached(i int) (string, bool) {
+	t.openCache()
+	if t.dir == "" {
+		return "", false
+	}
+	b, err := os.ReadFile(filepath.Join(t.dir, strconv.Itoa(i)+".txt"))
+	if err != nil {
+		return "", false
+	}
+
+	return string(b), true
+}
+
+// store caches the text of the chapter at index i of the spine on disk,
+// replacing the file atomically so that a session cut short leaves no
+// partial text behind.
+func (t *bookText) store(i int, s string) {
+	if t.dir == "" {
+		return
+	}
+	path := filepath.Join(t.dir, strconv.Itoa(i)+".txt")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(s), 0644); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+// fileHash returns the SHA-256 hash of the file called name, in hex.
+func fileHash(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// extractText returns the plain text of the chapter at index i of a book's
+// spine, as it is displayed without images.
+func extractText(book *epub.Rootfile, i int) (string, error) {
+	f, err := book.Spine.Itemrefs[i].Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	doc, err := render.Parse(f, book.Manifest.Items, render.Options{})
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(doc.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/taylorskalyo/goreader/epub"
+	"github.com/taylorskalyo/goreader/render"
+	"golang.org/x/term"
+)
+
+// catWidth returns the width books are written to stdout with. An explicit
+// width takes precedence; otherwise text is narrowed to fit the terminal,
+// when stdout is one.
+func catWidth(width int, s settings) int {
+	if width > 0 {
+		return width
+	}
+
+	width = s.MaxLineWidth
+	fd := int(os.Stdout.Fd())
+	if term.IsTerminal(fd) {
+		if w, _, err := term.GetSize(fd); err == nil && w < width {
+			width = w
+		}
+	}
+
+	return width
+}
+
+// cat writes every book in the queue to w, each laid out with its own
+// settings and wrapped at columns (see catWidth). Books are separated by blank
+// lines.
+func (a *app) cat(w io.Writer, columns int) error {
+	for i, name := range a.queue {
+		if i != a.current {
+			b, err := openBook(name)
+			if err != nil {
+				return fmt.Errorf("opening %s: %w", name, err)
+			}
+			a.setBook(i, b)
+			fmt.Fprintln(w)
+		}
+
+		opts := a.settings.renderOptions()
+		opts.Width = catWidth(columns, a.settings)
+		if err := catBook(w, a.book, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// catBook writes every chapter of a book to w as plain text, laid out as in
+// the pager and separated by blank lines.
+func catBook(w io.Writer, book *epub.Rootfile, opts render.Options) error {
+	bw := bufio.NewWriter(w)
+	for i, itemref := range book.Spine.Itemrefs {
+		f, err := itemref.Open()
+		if err != nil {
+			return err
+		}
+		doc, err := render.Parse(f, book.Manifest.Items, opts)
+		f.Close()
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Fprintln(bw)
+		}
+		if text := doc.String(); text != "" {
+			fmt.Fprintln(bw, text)
+		}
+	}
+
+	return bw.Flush()
+}
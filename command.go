@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// commandPrompt precedes the command being typed in the status bar.
+const commandPrompt = ":"
+
+// readCommand reads a command typed into the status bar, after prompt, until
+// it is entered or dismissed. It returns false if it was dismissed.
+func (a *app) readCommand(prompt string) (string, bool, error) {
+	var cmd []rune
+	defer func() { a.message = "" }()
+	defer termbox.HideCursor()
+	for {
+		a.message = prompt + string(cmd)
+		_, height := termbox.Size()
+		termbox.SetCursor(len([]rune(a.message))+1, height-statusBarHeight)
+		if err := a.draw(); err != nil {
+			return "", false, err
+		}
+
+		switch ev := termbox.PollEvent(); ev.Type {
+		case termbox.EventKey:
+			switch ev.Key {
+			case termbox.KeyEsc:
+				return "", false, nil
+			case termbox.KeyEnter:
+				return strings.TrimSpace(string(cmd)), true, nil
+			case termbox.KeyBackspace, termbox.KeyBackspace2:
+				if len(cmd) == 0 {
+					return "", false, nil
+				}
+				cmd = cmd[:len(cmd)-1]
+			case termbox.KeySpace:
+				cmd = append(cmd, ' ')
+			default:
+				if ev.Ch != 0 {
+					cmd = append(cmd, ev.Ch)
+				}
+			}
+		case termbox.EventError:
+			return "", false, ev.Err
+		}
+	}
+}
+
+// command reads a command and runs it. Commands are:
+//
+//	L<n>  go to row n of the chapter, counting from one, or to the row
+//	      numbered n in the gutter when line numbers continue through the
+//	      book
+func (a *app) command() error {
+	cmd, ok, err := a.readCommand(commandPrompt)
+	if err != nil || !ok || cmd == "" {
+		return err
+	}
+
+	switch {
+	case strings.HasPrefix(cmd, "L"):
+		n, err := strconv.Atoi(strings.TrimSpace(cmd[1:]))
+		if err != nil {
+			a.message = fmt.Sprintf("Not a row number: %s", cmd[1:])
+			return nil
+		}
+		a.toLine(n)
+	default:
+		a.message = fmt.Sprintf("Unknown command: %s", cmd)
+	}
+
+	return nil
+}
+
+// toLine scrolls the pager to row n of the chapter, counting from one, or to
+// the row numbered n in the gutter when line numbers continue through the
+// book. Rows beyond the end of the chapter go to its last row, and those
+// before its start to its first.
+func (a *app) toLine(n int) {
+	if a.config.LineNumbering == lineNumbersContinuous && a.pager.firstLine > 0 {
+		n -= a.pager.firstLine - 1
+	}
+	row := clamp(n-1, 0, max(a.pager.doc.Rows()-1, 0))
+	a.pager.scrollX = 0
+	a.pager.toRow(row)
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/taylorskalyo/goreader/render"
+)
+
+// settings controls how a book is displayed. Global settings are read from
+// the config file and may be overridden for individual books.
+type settings struct {
+	// MaxLineWidth is the widest the text column may be, including margins.
+	// The column is centered in terminals that are wider.
+	MaxLineWidth int `json:"max_line_width"`
+
+	// Margin is the number of blank columns on either side of the text.
+	Margin int `json:"margin"`
+
+	// Images controls whether images are rendered as ASCII art.
+	Images bool `json:"images"`
+
+	// Brightness and Contrast adjust images before they are rendered as
+	// ASCII art. Both are percentages from -100 to 100.
+	Brightness int `json:"brightness"`
+	Contrast   int `json:"contrast"`
+
+	// ImageCaption is the format of the caption shown for images, with
+	// {alt}, {src} and {title} placeholders.
+	ImageCaption string `json:"image_caption"`
+
+	// ThumbnailWidth is the most columns images are rendered to, as
+	// thumbnails with a hint to open them in the viewer. Zero renders them
+	// at the full width of the text.
+	ThumbnailWidth int `json:"thumbnail_width"`
+
+	// LineSpacing is the number of blank rows inserted between lines.
+	LineSpacing int `json:"line_spacing"`
+
+	// MaxBlankLines is the most blank lines left by a run of line breaks
+	// (<br>).
+	MaxBlankLines int `json:"max_blank_lines"`
+
+	// Highlight controls whether code blocks that declare their language
+	// (e.g. class="language-go") are syntax highlighted.
+	Highlight bool `json:"highlight"`
+
+	// Italic is how italic and emphasized text is shown, since terminals
+	// have no italic attribute. It is either a color (e.g. "cyan"), "bold",
+	// "underline", "reverse", or a marker written around the text (e.g. "/"
+	// for /text/).
+	Italic string `json:"italic"`
+
+	// Layout is the name of the layout preset paragraphs are set out with
+	// (e.g. "novel").
+	Layout string `json:"layout"`
+
+	// Wrap is the line breaking algorithm: "greedy" or "balanced".
+	Wrap string `json:"wrap"`
+
+	// Ruby is how ruby annotations, such as furigana, are shown: "inline",
+	// in parentheses after their base text, or "hide".
+	Ruby string `json:"ruby"`
+
+	// ExpandDetails controls whether collapsible sections (<details>) are
+	// expanded when a chapter opens, rather than showing only their summary.
+	ExpandDetails bool `json:"expand_details"`
+
+	// ExpandAbbreviations controls whether the first occurrence of each
+	// abbreviation in a chapter is followed by its expansion.
+	ExpandAbbreviations bool `json:"expand_abbreviations"`
+
+	// SectionBreaks controls whether the parts, chapters and other
+	// divisions EPUB3 books mark with an epub:type are set apart from the
+	// text before them.
+	SectionBreaks bool `json:"section_breaks"`
+
+	// Paged controls whether the book is read a screen at a time, rather
+	// than scrolled through line by line.
+	Paged bool `json:"paged"`
+
+	// Split is the deepest heading level at which chapters are split into
+	// navigable sections. Zero disables splitting.
+	Split int `json:"split"`
+
+	// Theme is the name of the theme text is displayed with, either built in
+	// or defined in a theme file. Empty selects the default theme.
+	Theme string `json:"theme"`
+
+	// LineNumbers controls whether rows are numbered in a gutter to the left
+	// of the text.
+	LineNumbers bool `json:"line_numbers"`
+
+	// Focus controls whether all but the paragraph at the top of the screen
+	// are dimmed.
+	Focus bool `json:"focus"`
+}
+
+// minLineWidth is the narrowest maximum line width that can be set, and
+// lineWidthStep the amount each key press changes it by.
+const (
+	minLineWidth  = 20
+	lineWidthStep = 5
+)
+
+// maxMargin is the widest margin that can be set.
+const maxMargin = 30
+
+// maxLineSpacing is the largest line spacing that can be set.
+const maxLineSpacing = 3
+
+// maxBlankLines is the largest number of blank lines that line breaks can be
+// set to leave.
+const maxBlankLines = 3
+
+// maxImageLevel is the largest adjustment that can be made to image
+// brightness or contrast, in either direction. imageLevelStep is the amount
+// each key press changes it by.
+const (
+	maxImageLevel  = 100
+	imageLevelStep = 10
+)
+
+// defaultSettings are used when the config file does not specify otherwise.
+var defaultSettings = settings{
+	MaxLineWidth: render.DefaultWidth,
+	Images:       true,
+	Italic:       render.DefaultItalic,
+	ImageCaption: render.DefaultImageCaption,
+	Layout:       render.Layouts[0].Name,
+	Wrap:         render.WrapGreedy,
+	Ruby:         render.RubyInline,
+
+	MaxBlankLines: render.DefaultMaxBlankLines,
+}
+
+// normalize replaces out of range settings with the nearest valid value, or
+// the default where there is none.
+func (s *settings) normalize() {
+	if s.MaxLineWidth < minLineWidth {
+		s.MaxLineWidth = defaultSettings.MaxLineWidth
+	}
+	s.Margin = clamp(s.Margin, 0, maxMargin)
+	s.LineSpacing = clamp(s.LineSpacing, 0, maxLineSpacing)
+	if s.MaxBlankLines < 1 {
+		s.MaxBlankLines = defaultSettings.MaxBlankLines
+	}
+	s.MaxBlankLines = min(s.MaxBlankLines, maxBlankLines)
+	s.Brightness = clamp(s.Brightness, -maxImageLevel, maxImageLevel)
+	s.Contrast = clamp(s.Contrast, -maxImageLevel, maxImageLevel)
+	s.ThumbnailWidth = max(s.ThumbnailWidth, 0)
+	if _, ok := render.LookupLayout(s.Layout); !ok {
+		s.Layout = defaultSettings.Layout
+	}
+	if s.Wrap != render.WrapGreedy && s.Wrap != render.WrapBalanced {
+		s.Wrap = defaultSettings.Wrap
+	}
+	if s.Ruby != render.RubyInline && s.Ruby != render.RubyHide {
+		s.Ruby = defaultSettings.Ruby
+	}
+}
+
+// overrides returns the settings that differ from those of base, by their
+// names in the config file, so that a book saves only the settings it
+// changes and follows the config file for the rest.
+func (s settings) overrides(base settings) map[string]json.RawMessage {
+	var fields, baseFields map[string]json.RawMessage
+	if b, err := json.Marshal(s); err == nil {
+		json.Unmarshal(b, &fields)
+	}
+	if b, err := json.Marshal(base); err == nil {
+		json.Unmarshal(b, &baseFields)
+	}
+	for name, v := range fields {
+		if string(v) == string(baseFields[name]) {
+			delete(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return fields
+}
+
+// override returns s with the given settings, by their names in the config
+// file, laid over it. Settings of the wrong type are left as they are.
+func (s settings) override(fields map[string]json.RawMessage) settings {
+	if b, err := json.Marshal(fields); err == nil {
+		json.Unmarshal(b, &s)
+	}
+	s.normalize()
+
+	return s
+}
+
+// renderOptions returns the options books are laid out with.
+func (s settings) renderOptions() render.Options {
+	return render.Options{
+		Width:       s.MaxLineWidth,
+		Margin:      s.Margin,
+		LineSpacing: s.LineSpacing,
+		Split:       s.Split,
+		Images:      s.Images,
+		Brightness:  s.Brightness,
+		Contrast:    s.Contrast,
+		Highlight:   s.Highlight,
+		Italic:      s.Italic,
+		Layout:      s.Layout,
+		Wrap:        s.Wrap,
+		Ruby:        s.Ruby,
+
+		ImageCaption:   s.ImageCaption,
+		ThumbnailWidth: s.ThumbnailWidth,
+		ThumbnailHint:  thumbnailHint,
+		MaxBlankLines:  s.MaxBlankLines,
+		ExpandDetails:  s.ExpandDetails,
+
+		ExpandAbbreviations: s.ExpandAbbreviations,
+		SectionBreaks:       s.SectionBreaks,
+	}
+}
+
+// nextLayout returns the name of the layout preset after s's in
+// render.Layouts, wrapping around to the first.
+func (s settings) nextLayout() string {
+	for i, l := range render.Layouts {
+		if l.Name == s.Layout {
+			return render.Layouts[(i+1)%len(render.Layouts)].Name
+		}
+	}
+
+	return render.Layouts[0].Name
+}
+
+// clamp limits v to the range [min, max].
+func clamp(v, min, max int) int {
+	if v < min {
+		return min
+	} else if v > max {
+		return max
+	}
+
+	return v
+}
+
+// Chapters with no visible content are handled as named by
+// config.BlankChapters.
+const (
+	blankMark = "mark"
+	blankSkip = "skip"
+)
+
+// Rows are numbered from the start of each chapter, or through the whole
+// book, as named by config.LineNumbering.
+const (
+	lineNumbersChapter    = "chapter"
+	lineNumbersContinuous = "continuous"
+)
+
+// What is shown on moving from one chapter to the next, as named by
+// config.ChapterTransition: the top of the new chapter, that with the last
+// lines of the chapter before above it, or the new chapter's title on a
+// screen of its own until a key is pressed.
+const (
+	transitionTop          = "top"
+	transitionOverlap      = "overlap"
+	transitionInterstitial = "interstitial"
+)
+
+// How images are drawn, as named by config.ImageMode: in color on terminals
+// that advertise enough colors and as ASCII art otherwise, always in color, or
+// always as ASCII art.
+const (
+	imageModeAuto  = "auto"
+	imageModeColor = "color"
+	imageModeASCII = "ascii"
+)
+
+// defaultWordsPerMinute is the reading speed assumed when the config file
+// does not give one.
+const defaultWordsPerMinute = 250
+
+// config holds user preferences read from the config file.
+type config struct {
+	settings
+
+	// Stats controls whether reading sessions are logged.
+	Stats bool `json:"stats"`
+
+	// StartAtBody controls whether books open at the start of their main body
+	// of text, skipping front matter, when the book declares where that is.
+	StartAtBody bool `json:"start_at_body"`
+
+	// ScrollChapters controls whether scrolling past the end of a chapter
+	// line by line continues into the next, rather than stopping there.
+	ScrollChapters bool `json:"scroll_chapters"`
+
+	// BlankChapters is what is done with chapters that have no visible
+	// content, such as blank pages or section dividers: blankMark shows a
+	// marker in place of their content, and blankSkip also passes over them
+	// when paging or scrolling into them from another chapter.
+	BlankChapters string `json:"blank_chapters"`
+
+	// ChapterTransition is what is shown on paging or scrolling into another
+	// chapter, or moving to one with H or L: transitionTop,
+	// transitionOverlap or transitionInterstitial.
+	ChapterTransition string `json:"chapter_transition"`
+
+	// LineNumbering is where line numbers count from: lineNumbersChapter
+	// starts from one in each chapter, and lineNumbersContinuous carries on
+	// from the end of the chapter before.
+	LineNumbering string `json:"line_numbering"`
+
+	// Night dims the display between two times of day.
+	Night nightShift `json:"night"`
+
+	// Status is the format of the status bar. Placeholders such as
+	// {chapter} are replaced with details of the reading position.
+	Status string `json:"status"`
+
+	// WordsPerMinute is the reading speed the time left in a chapter is
+	// reckoned at.
+	WordsPerMinute int `json:"words_per_minute"`
+
+	// Viewer is the command images and media are opened with, in place of
+	// the system's default application.
+	Viewer string `json:"viewer"`
+
+	// HeadingLevel is the deepest heading level that [[ and ]] jump
+	// between, from 1 to maxHeadingLevel.
+	HeadingLevel int `json:"heading_level"`
+
+	// NoColor displays text without colors, using only text attributes
+	// such as bold. It defaults to whether the NO_COLOR environment
+	// variable is set.
+	NoColor bool `json:"no_color"`
+
+	// ImageMode is how images are drawn: imageModeAuto, imageModeColor or
+	// imageModeASCII.
+	ImageMode string `json:"image_mode"`
+}
+
+// colorImages reports whether images are drawn in color. Unless ImageMode
+// says otherwise, they are on terminals that advertise 256 colors or more,
+// through TERM (e.g. "xterm-256color") or COLORTERM ("truecolor" or "24bit").
+// Images are never drawn in color when text is displayed without colors.
+func (c config) colorImages() bool {
+	switch {
+	case c.NoColor || c.ImageMode == imageModeASCII:
+		return false
+	case c.ImageMode == imageModeColor:
+		return true
+	}
+
+	colorTerm := os.Getenv("COLORTERM")
+	return colorTerm == "truecolor" || colorTerm == "24bit" || strings.Contains(os.Getenv("TERM"), "256color")
+}
+
+// configDir returns the directory goreader stores its files in.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "goreader"), nil
+}
+
+// loadConfig reads the config file. Defaults are returned if the file does not
+// exist.
+func loadConfig() (config, error) {
+	cfg := config{settings: defaultSettings, StartAtBody: true, ScrollChapters: true, BlankChapters: blankMark, ChapterTransition: transitionTop, LineNumbering: lineNumbersChapter, ImageMode: imageModeAuto, WordsPerMinute: defaultWordsPerMinute, Status: defaultStatus, HeadingLevel: maxHeadingLevel, NoColor: os.Getenv("NO_COLOR") != ""}
+
+	dir, err := configDir()
+	if err != nil {
+		return cfg, nil
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, err
+	}
+	if err := cfg.Night.validate(); err != nil {
+		return cfg, err
+	}
+	cfg.normalize()
+	if cfg.BlankChapters != blankSkip {
+		cfg.BlankChapters = blankMark
+	}
+	if cfg.ChapterTransition != transitionOverlap && cfg.ChapterTransition != transitionInterstitial {
+		cfg.ChapterTransition = transitionTop
+	}
+	if cfg.LineNumbering != lineNumbersContinuous {
+		cfg.LineNumbering = lineNumbersChapter
+	}
+	if cfg.ImageMode != imageModeColor && cfg.ImageMode != imageModeASCII {
+		cfg.ImageMode = imageModeAuto
+	}
+	if cfg.WordsPerMinute <= 0 {
+		cfg.WordsPerMinute = defaultWordsPerMinute
+	}
+	if cfg.HeadingLevel < 1 || cfg.HeadingLevel > maxHeadingLevel {
+		cfg.HeadingLevel = maxHeadingLevel
+	}
+
+	return cfg, nil
+}
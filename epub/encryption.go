@@ -0,0 +1,190 @@
+package epub
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const encryptionPath = "META-INF/encryption.xml"
+
+// ErrEncrypted occurs when opening an item that is encrypted with an
+// algorithm other than font obfuscation, e.g. by DRM.
+var ErrEncrypted = errors.New("epub: item is encrypted")
+
+// Font obfuscation algorithms, as named in encryption.xml. Both XOR the start
+// of a resource with a key derived from the book's identifier.
+const (
+	idpfObfuscation  = "http://www.idpf.org/2008/embedding"
+	adobeObfuscation = "http://ns.adobe.com/pdf/enc#RC"
+)
+
+// encryption is the content of an epub's encryption.xml, which lists the
+// resources that are encrypted or obfuscated.
+type encryption struct {
+	Data []struct {
+		Method struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"EncryptionMethod"`
+		Reference struct {
+			URI string `xml:"URI,attr"`
+		} `xml:"CipherData>CipherReference"`
+	} `xml:"EncryptedData"`
+}
+
+// packageIdentifiers reads the identifiers of a package, along with the ID of
+// the one that uniquely identifies it, which cannot be unmarshaled along with
+// its metadata.
+type packageIdentifiers struct {
+	UniqueIdentifier string `xml:"unique-identifier,attr"`
+	Identifiers      []struct {
+		ID    string `xml:"id,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"metadata>identifier"`
+}
+
+// uniqueIdentifier returns the value of the identifier that uniquely
+// identifies a package, or failing that its first identifier.
+func (pi packageIdentifiers) uniqueIdentifier() string {
+	for _, id := range pi.Identifiers {
+		if id.ID == pi.UniqueIdentifier {
+			return id.Value
+		}
+	}
+	if len(pi.Identifiers) > 0 {
+		return pi.Identifiers[0].Value
+	}
+
+	return ""
+}
+
+// setEncryption reads the epub's encryption.xml, if it has one, so that
+// obfuscated resources are deobfuscated as they are read and encrypted ones
+// fail to open with ErrEncrypted. Resources are obfuscated with a key derived
+// from the first package's unique identifier. An unreadable encryption.xml is
+// ignored, leaving the resources as they are stored.
+func (r *Reader) setEncryption() {
+	f := r.files[encryptionPath]
+	if f == nil || len(r.Container.Rootfiles) == 0 {
+		return
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return
+	}
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return
+	}
+	var enc encryption
+	if err := xml.Unmarshal(b, &enc); err != nil {
+		return
+	}
+
+	id := r.Container.Rootfiles[0].uniqueID
+	for _, d := range enc.Data {
+		name, err := url.PathUnescape(d.Reference.URI)
+		if err != nil {
+			name = d.Reference.URI
+		}
+		name = strings.TrimPrefix(name, "/")
+		f := r.files[name]
+		if f == nil {
+			continue
+		}
+
+		switch d.Method.Algorithm {
+		case idpfObfuscation:
+			r.files[name] = obfuscatedFile{file: f, key: idpfKey(id), length: 1040}
+		case adobeObfuscation:
+			if key, ok := adobeKey(id); ok {
+				r.files[name] = obfuscatedFile{file: f, key: key, length: 1024}
+			} else {
+				r.files[name] = encryptedFile{name}
+			}
+		default:
+			r.files[name] = encryptedFile{name}
+		}
+	}
+}
+
+// idpfKey returns the key the IDPF algorithm obfuscates resources with: the
+// SHA-1 digest of the book's unique identifier, without white space.
+func idpfKey(id string) []byte {
+	id = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, id)
+	sum := sha1.Sum([]byte(id))
+
+	return sum[:]
+}
+
+// adobeKey returns the key Adobe's algorithm obfuscates resources with: the
+// bytes of the UUID that identifies the book (e.g.
+// "urn:uuid:01234567-89ab-cdef-0123-456789abcdef"). It returns false if the
+// identifier is not a UUID.
+func adobeKey(id string) ([]byte, bool) {
+	id = strings.TrimPrefix(strings.TrimSpace(id), "urn:uuid:")
+	key, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+	if err != nil || len(key) != 16 {
+		return nil, false
+	}
+
+	return key, true
+}
+
+// obfuscatedFile is a file whose first length bytes are XORed with key,
+// repeated as often as needed.
+type obfuscatedFile struct {
+	file
+	key    []byte
+	length int
+}
+
+// Open opens the file for reading, deobfuscating its content.
+func (of obfuscatedFile) Open() (io.ReadCloser, error) {
+	rc, err := of.file.Open()
+	if err != nil {
+		return nil, err
+	}
+
+	return &deobfuscator{ReadCloser: rc, key: of.key, length: of.length}, nil
+}
+
+// deobfuscator reverses the obfuscation of an obfuscatedFile as it is read.
+// read is the number of bytes read so far.
+type deobfuscator struct {
+	io.ReadCloser
+	key          []byte
+	length, read int
+}
+
+func (d *deobfuscator) Read(b []byte) (int, error) {
+	n, err := d.ReadCloser.Read(b)
+	for i := 0; i < n && d.read < d.length; i++ {
+		b[i] ^= d.key[d.read%len(d.key)]
+		d.read++
+	}
+
+	return n, err
+}
+
+// encryptedFile is a file that cannot be read, since it is encrypted.
+type encryptedFile struct {
+	name string
+}
+
+// Open returns ErrEncrypted.
+func (ef encryptedFile) Open() (io.ReadCloser, error) {
+	return nil, fmt.Errorf("%w: %s", ErrEncrypted, ef.name)
+}
@@ -9,14 +9,20 @@ import (
 	"bytes"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 )
 
 const containerPath = "META-INF/container.xml"
 
 var (
+	// ErrNoContainer occurs when a zip archive or directory has no
+	// META-INF/container.xml, and so is not an epub.
+	ErrNoContainer = errors.New("epub: not an epub, " + containerPath + " is missing")
+
 	// ErrNoRootfile occurs when there are no rootfile entries found in
 	// container.xml.
 	ErrNoRootfile = errors.New("epub: no rootfile found in container")
@@ -41,7 +47,23 @@ var (
 // Reader represents a readable epub file.
 type Reader struct {
 	Container
-	files map[string]*zip.File
+	files map[string]file
+}
+
+// file is a single entry in an epub, stored either in a zip archive or in an
+// unpacked directory.
+type file interface {
+	Open() (io.ReadCloser, error)
+}
+
+// dirFile is a file stored on disk as part of an unpacked epub directory.
+type dirFile struct {
+	path string
+}
+
+// Open opens the file for reading.
+func (df dirFile) Open() (io.ReadCloser, error) {
+	return os.Open(df.path)
 }
 
 // ReadCloser represents a readable epub file that can be closed.
@@ -66,13 +88,39 @@ type Package struct {
 	Metadata
 	Manifest
 	Spine
+	Guide
+
+	// Landmarks lists the key structural components of the epub, taken from
+	// the EPUB3 navigation document or, failing that, the EPUB2 guide.
+	Landmarks []Landmark `xml:"-"`
+
+	// TOC is the table of contents, taken from the EPUB3 navigation
+	// document or, failing that, the EPUB2 NCX. Nested entries follow the
+	// entry they belong to.
+	TOC []TOCEntry `xml:"-"`
+
+	// ncxID is the ID of the item holding the EPUB2 NCX, as named by the
+	// spine's toc attribute.
+	ncxID string
+
+	// uniqueID is the package's unique identifier, from which obfuscated
+	// resources' keys are derived.
+	uniqueID string
+}
+
+// spineTOC reads the toc attribute of a package's spine, which cannot be
+// unmarshaled along with the spine's itemrefs.
+type spineTOC struct {
+	Spine struct {
+		TOC string `xml:"toc,attr"`
+	} `xml:"spine"`
 }
 
 // Metadata contains publishing information about the epub.
 type Metadata struct {
 	Title       string `xml:"metadata>title"`
 	Language    string `xml:"metadata>language"`
-	Identifier  string `xml:"metadata>idenifier"`
+	Identifier  string `xml:"metadata>identifier"`
 	Creator     string `xml:"metadata>creator"`
 	Contributor string `xml:"metadata>contributor"`
 	Publisher   string `xml:"metadata>publisher"`
@@ -97,10 +145,11 @@ type Manifest struct {
 
 // Item represents a file stored in the epub.
 type Item struct {
-	ID        string `xml:"id,attr"`
-	HREF      string `xml:"href,attr"`
-	MediaType string `xml:"media-type,attr"`
-	f         *zip.File
+	ID         string `xml:"id,attr"`
+	HREF       string `xml:"href,attr"`
+	MediaType  string `xml:"media-type,attr"`
+	Properties string `xml:"properties,attr"`
+	f          file
 }
 
 // Spine defines the reading order of the epub documents.
@@ -111,11 +160,15 @@ type Spine struct {
 // Itemref points to an Item.
 type Itemref struct {
 	IDREF string `xml:"idref,attr"`
+
+	// Linear is "no" for items that are not part of the book's main reading
+	// order, such as notes or a cover page, and "yes" or empty otherwise.
+	Linear string `xml:"linear,attr"`
 	*Item
 }
 
 // OpenReader will open the epub file specified by name and return a
-// ReadCloser.
+// ReadCloser. If name is a directory, it is read as an unpacked epub.
 func OpenReader(name string) (*ReadCloser, error) {
 	f, err := os.Open(name)
 	if err != nil {
@@ -131,12 +184,24 @@ func OpenReader(name string) (*ReadCloser, error) {
 		return nil, err
 	}
 
+	if fi.IsDir() {
+		f.Close()
+		rc.f = nil
+		if err = rc.initDir(name); err != nil {
+			return nil, err
+		}
+
+		return rc, nil
+	}
+
 	z, err := zip.NewReader(f, fi.Size())
 	if err != nil {
-		return nil, err
+		f.Close()
+		return nil, fmt.Errorf("epub: reading %s: %w", name, err)
 	}
 
 	if err = rc.init(z); err != nil {
+		f.Close()
 		return nil, err
 	}
 
@@ -148,7 +213,7 @@ func OpenReader(name string) (*ReadCloser, error) {
 func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
 	z, err := zip.NewReader(ra, size)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("epub: reading archive: %w", err)
 	}
 
 	r := new(Reader)
@@ -161,11 +226,45 @@ func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
 
 func (r *Reader) init(z *zip.Reader) error {
 	// Create a file lookup table
-	r.files = make(map[string]*zip.File)
+	r.files = make(map[string]file)
 	for _, f := range z.File {
 		r.files[f.Name] = f
 	}
 
+	return r.load()
+}
+
+// initDir reads an unpacked epub rooted at the directory dir.
+func (r *Reader) initDir(dir string) error {
+	// Create a file lookup table, keyed by slash-separated paths relative to
+	// dir to match the layout of a zip archive.
+	r.files = make(map[string]file)
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		r.files[filepath.ToSlash(rel)] = dirFile{p}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.load()
+}
+
+// load parses the epub's container and package files and links items to
+// their underlying files.
+func (r *Reader) load() error {
 	err := r.setContainer()
 	if err != nil {
 		return err
@@ -174,30 +273,40 @@ func (r *Reader) init(z *zip.Reader) error {
 	if err != nil {
 		return err
 	}
+	r.setEncryption()
 	err = r.setItems()
 	if err != nil {
 		return err
 	}
+	err = r.setNav()
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
 // setContainer unmarshals the epub's container.xml file.
 func (r *Reader) setContainer() error {
+	if r.files[containerPath] == nil {
+		return ErrNoContainer
+	}
+
 	f, err := r.files[containerPath].Open()
 	if err != nil {
-		return err
+		return fmt.Errorf("epub: opening %s: %w", containerPath, err)
 	}
+	defer f.Close()
 
 	var b bytes.Buffer
 	_, err = io.Copy(&b, f)
 	if err != nil {
-		return err
+		return fmt.Errorf("epub: reading %s: %w", containerPath, err)
 	}
 
 	err = xml.Unmarshal(b.Bytes(), &r.Container)
 	if err != nil {
-		return err
+		return fmt.Errorf("epub: parsing %s: %w", containerPath, err)
 	}
 
 	if len(r.Container.Rootfiles) < 1 {
@@ -211,23 +320,33 @@ func (r *Reader) setContainer() error {
 func (r *Reader) setPackages() error {
 	for _, rf := range r.Container.Rootfiles {
 		if r.files[rf.FullPath] == nil {
-			return ErrBadRootfile
+			return fmt.Errorf("%w: %s", ErrBadRootfile, rf.FullPath)
 		}
 
 		f, err := r.files[rf.FullPath].Open()
 		if err != nil {
-			return err
+			return fmt.Errorf("epub: opening %s: %w", rf.FullPath, err)
 		}
 
 		var b bytes.Buffer
 		_, err = io.Copy(&b, f)
+		f.Close()
 		if err != nil {
-			return err
+			return fmt.Errorf("epub: reading %s: %w", rf.FullPath, err)
 		}
 
 		err = xml.Unmarshal(b.Bytes(), &rf.Package)
 		if err != nil {
-			return err
+			return fmt.Errorf("epub: parsing %s: %w", rf.FullPath, err)
+		}
+
+		var st spineTOC
+		if err := xml.Unmarshal(b.Bytes(), &st); err == nil {
+			rf.ncxID = st.Spine.TOC
+		}
+		var pi packageIdentifiers
+		if err := xml.Unmarshal(b.Bytes(), &pi); err == nil {
+			rf.uniqueID = pi.uniqueIdentifier()
 		}
 	}
 
@@ -252,7 +371,7 @@ func (r *Reader) setItems() error {
 			itemref := &rf.Spine.Itemrefs[i]
 			itemref.Item = itemMap[itemref.IDREF]
 			if itemref.Item == nil {
-				return ErrBadItemref
+				return fmt.Errorf("%w: %s", ErrBadItemref, itemref.IDREF)
 			}
 		}
 		itemrefCount += len(rf.Spine.Itemrefs)
@@ -269,7 +388,7 @@ func (r *Reader) setItems() error {
 // Multiple items may be read concurrently.
 func (item *Item) Open() (r io.ReadCloser, err error) {
 	if item.f == nil {
-		return nil, ErrBadManifest
+		return nil, fmt.Errorf("%w: %s", ErrBadManifest, item.HREF)
 	}
 
 	return item.f.Open()
@@ -277,5 +396,7 @@ func (item *Item) Open() (r io.ReadCloser, err error) {
 
 // Close closes the epub file, rendering it unusable for I/O.
 func (rc *ReadCloser) Close() {
-	rc.f.Close()
+	if rc.f != nil {
+		rc.f.Close()
+	}
 }
@@ -1,7 +1,14 @@
 package epub
 
 import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -46,12 +53,72 @@ func TestNewReader(t *testing.T) {
 	})
 }
 
+func TestOpenReaderDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goreader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := unzip("_test_files/alice.epub", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenReader(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	t.Run("Directory", func(t *testing.T) {
+		tt := containerTest{t, r.Container}
+		tt.TestContainer()
+	})
+}
+
+// unzip extracts the zip archive src into the directory dst.
+func unzip(src, dst string) error {
+	z, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer z.Close()
+
+	for _, zf := range z.File {
+		p := filepath.Join(dst, filepath.FromSlash(zf.Name))
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(p)
+		if err != nil {
+			rc.Close()
+			return err
+		}
+		_, err = io.Copy(f, rc)
+		rc.Close()
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (ct *containerTest) TestContainer() {
 	ct.Run("Container", func(t *testing.T) {
 		tt := containerTest{t, ct.c}
 		tt.TestMetadata()
 		tt.TestSpine()
 		tt.TestManifest()
+		tt.TestLandmarks()
 	})
 }
 
@@ -67,15 +134,21 @@ func (ct *containerTest) TestMetadata() {
 	if meta.Creator != exp {
 		ct.Errorf(expFormat, exp, meta.Creator)
 	}
+
+	exp = "http://www.gutenberg.org/ebooks/28885"
+	if meta.Identifier != exp {
+		ct.Errorf(expFormat, exp, meta.Identifier)
+	}
 }
 
 func (ct *containerTest) TestSpine() {
 	testCases := []struct {
 		itemrefIndex int
 		expIDREF     string
+		expLinear    string
 	}{
-		{0, "coverpage-wrapper"},
-		{1, "item41"},
+		{0, "coverpage-wrapper", "no"},
+		{1, "item41", "yes"},
 	}
 
 	spine := ct.c.Rootfiles[0].Spine
@@ -85,6 +158,9 @@ func (ct *containerTest) TestSpine() {
 			if itemref.IDREF != tc.expIDREF {
 				t.Errorf(expFormat, tc.expIDREF, itemref.IDREF)
 			}
+			if itemref.Linear != tc.expLinear {
+				t.Errorf(expFormat, tc.expLinear, itemref.Linear)
+			}
 
 			if itemref.Item == nil {
 				t.Errorf(expFormat, "not nil", "nil")
@@ -128,3 +204,431 @@ func (ct *containerTest) TestManifest() {
 		})
 	}
 }
+
+func (ct *containerTest) TestLandmarks() {
+	testCases := []struct {
+		types    []string
+		expTitle string
+		expHREF  string
+	}{
+		{
+			[]string{"toc"},
+			"CONTENTS",
+			"@public@vhost@g@gutenberg@html@files@28885@28885-h@28885-h-0.htm.html#pgepubid00001",
+		},
+		{
+			[]string{"bodymatter", "cover"},
+			"Cover",
+			"wrap0000.html",
+		},
+	}
+
+	rf := ct.c.Rootfiles[0]
+	for _, tc := range testCases {
+		ct.Run("Landmark", func(t *testing.T) {
+			l, ok := rf.Landmark(tc.types...)
+			if !ok {
+				t.Fatalf(expFormat, tc.expTitle, "no landmark")
+			}
+			if l.Title != tc.expTitle {
+				t.Errorf(expFormat, tc.expTitle, l.Title)
+			}
+			if l.HREF != tc.expHREF {
+				t.Errorf(expFormat, tc.expHREF, l.HREF)
+			}
+		})
+	}
+
+	i, ok := rf.SpineIndex("wrap0000.html")
+	if !ok || i != 0 {
+		ct.Errorf(expFormat, 0, i)
+	}
+}
+
+// testOPF is a minimal EPUB3 package document. Its manifest is completed with
+// the given items.
+const testOPF = `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Test</dc:title>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav/nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="c1" href="text/c1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c2" href="text/c2.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine>
+    <itemref idref="c1"/>
+    <itemref idref="c2"/>
+  </spine>
+  <guide>
+    <reference type="text" title="Guide Start" href="text/c1.xhtml"/>
+  </guide>
+</package>`
+
+// newTestReader returns a Reader for a zipped epub containing the given files,
+// in addition to a container that points to OEBPS/content.opf.
+func newTestReader(t *testing.T, files map[string]string) (*Reader, error) {
+	files[containerPath] = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`
+	b := testZip(t, files)
+
+	return NewReader(bytes.NewReader(b), int64(len(b)))
+}
+
+// testZip returns a zip archive of the given files.
+func testZip(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	for name, content := range files {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func TestOpenErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		files  map[string]string
+		expErr error
+		expMsg string
+	}{
+		{
+			"NotEPUB",
+			map[string]string{"notes.txt": "hello"},
+			ErrNoContainer, "META-INF/container.xml",
+		},
+		{
+			"MissingPackage",
+			map[string]string{"OEBPS/text/c1.xhtml": "One"},
+			ErrBadRootfile, "OEBPS/content.opf",
+		},
+		{
+			"BadPackage",
+			map[string]string{"OEBPS/content.opf": "<package><metadata>"},
+			nil, "parsing OEBPS/content.opf",
+		},
+		{
+			"BadItemref",
+			map[string]string{"OEBPS/content.opf": `<package><spine><itemref idref="missing"/></spine></package>`},
+			ErrBadItemref, "missing",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			if tc.expErr == ErrNoContainer {
+				b := testZip(t, tc.files)
+				_, err = NewReader(bytes.NewReader(b), int64(len(b)))
+			} else {
+				_, err = newTestReader(t, tc.files)
+			}
+			if err == nil {
+				t.Fatalf(expFormat, tc.expMsg, err)
+			}
+			if tc.expErr != nil && !errors.Is(err, tc.expErr) {
+				t.Errorf(expFormat, tc.expErr, err)
+			}
+			if !strings.Contains(err.Error(), tc.expMsg) {
+				t.Errorf(expFormat, tc.expMsg, err)
+			}
+		})
+	}
+
+	t.Run("NotZip", func(t *testing.T) {
+		b := []byte("not a zip archive")
+		if _, err := NewReader(bytes.NewReader(b), int64(len(b))); !errors.Is(err, zip.ErrFormat) {
+			t.Errorf(expFormat, zip.ErrFormat, err)
+		}
+	})
+}
+
+func TestNavLandmarks(t *testing.T) {
+	r, err := newTestReader(t, map[string]string{
+		"OEBPS/content.opf":   testOPF,
+		"OEBPS/text/c1.xhtml": "<html><body>One</body></html>",
+		"OEBPS/text/c2.xhtml": "<html><body>Two</body></html>",
+		"OEBPS/nav/nav.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<body>
+  <nav epub:type="toc">
+    <ol>
+      <li><a href="../text/c1.xhtml">One</a></li>
+      <li><a href="../text/c2.xhtml">Two</a>
+        <ol><li><a href="../text/c2.xhtml#half">Two and a half</a></li></ol>
+      </li>
+    </ol>
+  </nav>
+  <nav epub:type="landmarks" hidden="">
+    <ol>
+      <li><a epub:type="toc" href="nav.xhtml#toc">Contents</a></li>
+      <li><a epub:type="bodymatter" href="../text/c2.xhtml#start">Start of <em>Content</em></a></li>
+    </ol>
+  </nav>
+</body>
+</html>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rf := r.Rootfiles[0]
+	if len(rf.Landmarks) != 2 {
+		t.Fatalf(expFormat, 2, len(rf.Landmarks))
+	}
+
+	l, ok := rf.Landmark("bodymatter", "text")
+	if !ok {
+		t.Fatalf(expFormat, "bodymatter", "no landmark")
+	}
+	if exp := "Start of Content"; l.Title != exp {
+		t.Errorf(expFormat, exp, l.Title)
+	}
+	if exp := "text/c2.xhtml#start"; l.HREF != exp {
+		t.Errorf(expFormat, exp, l.HREF)
+	}
+	if i, _ := rf.SpineIndex(l.HREF); i != 1 {
+		t.Errorf(expFormat, 1, i)
+	}
+
+	l, _ = rf.Landmark("toc")
+	if exp := "nav/nav.xhtml#toc"; l.HREF != exp {
+		t.Errorf(expFormat, exp, l.HREF)
+	}
+
+	expTOC := []TOCEntry{
+		{Title: "One", HREF: "text/c1.xhtml", Depth: 0},
+		{Title: "Two", HREF: "text/c2.xhtml", Depth: 0},
+		{Title: "Two and a half", HREF: "text/c2.xhtml#half", Depth: 1},
+	}
+	if len(rf.TOC) != len(expTOC) {
+		t.Fatalf(expFormat, expTOC, rf.TOC)
+	}
+	for i := range expTOC {
+		if rf.TOC[i] != expTOC[i] {
+			t.Errorf(expFormat, expTOC[i], rf.TOC[i])
+		}
+	}
+}
+
+func TestNCX(t *testing.T) {
+	opf := strings.Replace(testOPF, "<spine>", `<spine toc="ncx">`, 1)
+	opf = strings.Replace(opf, "</manifest>", `  <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+  </manifest>`, 1)
+	ncx := `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <navMap>
+    <navPoint id="p1" playOrder="1">
+      <navLabel><text>Chapter
+        One</text></navLabel>
+      <content src="text/c1.xhtml"/>
+      <navPoint id="p2" playOrder="2">
+        <navLabel><text>Part</text></navLabel>
+        <content src="text/c1.xhtml#part"/>
+      </navPoint>
+    </navPoint>
+    <navPoint id="p3" playOrder="3">
+      <navLabel><text>Chapter Two</text></navLabel>
+      <content src="text/c2.xhtml"/>
+    </navPoint>
+  </navMap>
+</ncx>`
+	navTOC := `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<body><nav epub:type="toc"><ol><li><a href="../text/c2.xhtml">Nav</a></li></ol></nav></body>
+</html>`
+	emptyNav := `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<body><nav epub:type="landmarks"><ol><li><a epub:type="bodymatter" href="../text/c1.xhtml">Start</a></li></ol></nav></body>
+</html>`
+	fromNCX := []TOCEntry{
+		{Title: "Chapter One", HREF: "text/c1.xhtml", Depth: 0},
+		{Title: "Part", HREF: "text/c1.xhtml#part", Depth: 1},
+		{Title: "Chapter Two", HREF: "text/c2.xhtml", Depth: 0},
+	}
+
+	testCases := []struct {
+		name string
+		opf  string
+		nav  string
+		exp  []TOCEntry
+	}{
+		{"Both", opf, navTOC, []TOCEntry{{Title: "Nav", HREF: "text/c2.xhtml"}}},
+		{"NCXOnly", strings.Replace(opf, ` properties="nav"`, "", 1), navTOC, fromNCX},
+		{"EmptyNav", opf, emptyNav, fromNCX},
+		{"MediaType", strings.Replace(opf, ` toc="ncx"`, "", 1), emptyNav, fromNCX},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := newTestReader(t, map[string]string{
+				"OEBPS/content.opf":   tc.opf,
+				"OEBPS/text/c1.xhtml": "<html><body>One</body></html>",
+				"OEBPS/text/c2.xhtml": "<html><body>Two</body></html>",
+				"OEBPS/nav/nav.xhtml": tc.nav,
+				"OEBPS/toc.ncx":       ncx,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			toc := r.Rootfiles[0].TOC
+			if len(toc) != len(tc.exp) {
+				t.Fatalf(expFormat, tc.exp, toc)
+			}
+			for i := range tc.exp {
+				if toc[i] != tc.exp[i] {
+					t.Errorf(expFormat, tc.exp[i], toc[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHiddenNav(t *testing.T) {
+	r, err := newTestReader(t, map[string]string{
+		"OEBPS/content.opf":   testOPF,
+		"OEBPS/text/c1.xhtml": "<html><body>One</body></html>",
+		"OEBPS/text/c2.xhtml": "<html><body>Two</body></html>",
+		"OEBPS/nav/nav.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<body>
+  <h1>Contents</h1>
+  <nav epub:type="toc" hidden="">
+    <ol>
+      <li><a href="../text/c1.xhtml">Part One</a>
+        <ol>
+          <li><a href="../text/c1.xhtml#one">Chapter One</a>
+            <ol><li><a href="../text/c1.xhtml#scene">Scene</a></li></ol>
+          </li>
+          <li><a href="../text/c2.xhtml">Chapter Two</a></li>
+        </ol>
+      </li>
+      <li><a href="../text/c2.xhtml#end">Afterword</a></li>
+    </ol>
+  </nav>
+</body>
+</html>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []TOCEntry{
+		{Title: "Part One", HREF: "text/c1.xhtml", Depth: 0},
+		{Title: "Chapter One", HREF: "text/c1.xhtml#one", Depth: 1},
+		{Title: "Scene", HREF: "text/c1.xhtml#scene", Depth: 2},
+		{Title: "Chapter Two", HREF: "text/c2.xhtml", Depth: 1},
+		{Title: "Afterword", HREF: "text/c2.xhtml#end", Depth: 0},
+	}
+	toc := r.Rootfiles[0].TOC
+	if len(toc) != len(exp) {
+		t.Fatalf(expFormat, exp, toc)
+	}
+	for i := range exp {
+		if toc[i] != exp[i] {
+			t.Errorf(expFormat, exp[i], toc[i])
+		}
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	const uuid = "urn:uuid:01234567-89ab-cdef-0123-456789abcdef"
+	opf := strings.Replace(testOPF, `version="3.0">`, `version="3.0" unique-identifier="uid">`, 1)
+	opf = strings.Replace(opf, "<dc:title>Test</dc:title>", `<dc:identifier>isbn</dc:identifier>
+    <dc:identifier id="uid"> `+uuid+` </dc:identifier>
+    <dc:title>Test</dc:title>`, 1)
+	opf = strings.Replace(opf, "</manifest>", `  <item id="f1" href="fonts/a font.otf" media-type="font/otf"/>
+    <item id="f2" href="fonts/b.otf" media-type="font/otf"/>
+    <item id="i1" href="images/c.png" media-type="image/png"/>
+    <item id="i2" href="images/d.png" media-type="image/png"/>
+  </manifest>`, 1)
+	encryption := `<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/>
+    <enc:CipherData><enc:CipherReference URI="OEBPS/fonts/a%20font.otf"/></enc:CipherData>
+  </enc:EncryptedData>
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://ns.adobe.com/pdf/enc#RC"/>
+    <enc:CipherData><enc:CipherReference URI="OEBPS/fonts/b.otf"/></enc:CipherData>
+  </enc:EncryptedData>
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/>
+    <enc:CipherData><enc:CipherReference URI="OEBPS/images/c.png"/></enc:CipherData>
+  </enc:EncryptedData>
+</encryption>`
+
+	content := strings.Repeat("0123456789", 150)
+	obfuscate := func(key []byte, length int) string {
+		b := []byte(content)
+		for i := 0; i < length; i++ {
+			b[i] ^= key[i%len(key)]
+		}
+		return string(b)
+	}
+	adobe, _ := adobeKey(uuid)
+
+	r, err := newTestReader(t, map[string]string{
+		"OEBPS/content.opf":      opf,
+		"OEBPS/text/c1.xhtml":    "<html><body>One</body></html>",
+		"OEBPS/text/c2.xhtml":    "<html><body>Two</body></html>",
+		"OEBPS/nav/nav.xhtml":    "<html><body></body></html>",
+		"OEBPS/fonts/a font.otf": obfuscate(idpfKey(uuid), 1040),
+		"OEBPS/fonts/b.otf":      obfuscate(adobe, 1024),
+		"OEBPS/images/c.png":     "encrypted",
+		"OEBPS/images/d.png":     content,
+		encryptionPath:           encryption,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		id  string
+		err error
+	}{
+		{"f1", nil},
+		{"f2", nil},
+		{"i1", ErrEncrypted},
+		{"i2", nil},
+	}
+
+	items := r.Rootfiles[0].Manifest.Items
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			var item Item
+			for _, it := range items {
+				if it.ID == tc.id {
+					item = it
+				}
+			}
+			rc, err := item.Open()
+			if !errors.Is(err, tc.err) {
+				t.Fatalf(expFormat, tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			defer rc.Close()
+
+			b, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != content {
+				t.Errorf(expFormat, content[:20], string(b[:20]))
+			}
+		})
+	}
+}
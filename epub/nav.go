@@ -0,0 +1,381 @@
+package epub
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// opsNamespace is the namespace of epub-specific attributes, such as
+// epub:type, in content documents.
+const opsNamespace = "http://www.idpf.org/2007/ops"
+
+// Landmark points to a key structural component of the epub, such as the
+// cover or the start of the main body of text.
+type Landmark struct {
+	// Type identifies the component, e.g. "cover", "toc" or "bodymatter".
+	// Landmarks from an EPUB2 guide use the guide's types, e.g. "text".
+	Type  string
+	Title string
+
+	// HREF is the location of the component relative to the package
+	// document. It may include a fragment identifier.
+	HREF string
+}
+
+// Guide lists the key structural components of an EPUB2 epub.
+type Guide struct {
+	References []Reference `xml:"guide>reference"`
+}
+
+// Reference is an entry in a Guide.
+type Reference struct {
+	Type  string `xml:"type,attr"`
+	Title string `xml:"title,attr"`
+	HREF  string `xml:"href,attr"`
+}
+
+// TOCEntry is an entry in the table of contents.
+type TOCEntry struct {
+	Title string
+
+	// HREF is the location of the entry relative to the package document.
+	// It may include a fragment identifier.
+	HREF string
+
+	// Depth is how deeply the entry is nested, zero for top-level entries.
+	Depth int
+}
+
+// navEntry is an entry in a list within an EPUB3 navigation document.
+type navEntry struct {
+	Type     string
+	Title    string
+	HREF     string
+	Children []navEntry
+}
+
+// epubType returns the value of an element's epub:type attribute.
+func epubType(se xml.StartElement) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == "type" && (a.Name.Space == opsNamespace || a.Name.Space == "epub") {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// attr returns the value of the named attribute of an element.
+func attr(se xml.StartElement, name string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == name && a.Name.Space == "" {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// parseNav reads an EPUB3 navigation document and returns the entries of each
+// of its nav elements, keyed by the nav's epub:type.
+func parseNav(r io.Reader) (map[string][]navEntry, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+
+	navs := make(map[string][]navEntry)
+	var (
+		navType string
+		inNav   bool
+		stack   []*navEntry // open list items
+		top     []navEntry  // completed top-level entries
+		inLabel int         // depth of open a/span elements within an item
+	)
+
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "nav":
+				if !inNav {
+					inNav, navType, top = true, epubType(t), nil
+				}
+			case "li":
+				if inNav {
+					stack = append(stack, new(navEntry))
+				}
+			case "a", "span":
+				if inNav && len(stack) > 0 {
+					e := stack[len(stack)-1]
+					if e.Title == "" && e.HREF == "" {
+						e.HREF = attr(t, "href")
+						e.Type = epubType(t)
+						inLabel++
+					} else if inLabel > 0 {
+						inLabel++
+					}
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "nav":
+				if inNav {
+					navs[navType] = append(navs[navType], top...)
+					inNav, stack, inLabel = false, nil, 0
+				}
+			case "li":
+				if inNav && len(stack) > 0 {
+					e := stack[len(stack)-1]
+					e.Title = strings.Join(strings.Fields(e.Title), " ")
+					stack = stack[:len(stack)-1]
+					if len(stack) > 0 {
+						parent := stack[len(stack)-1]
+						parent.Children = append(parent.Children, *e)
+					} else {
+						top = append(top, *e)
+					}
+				}
+			case "a", "span":
+				if inLabel > 0 {
+					inLabel--
+				}
+			}
+		case xml.CharData:
+			if inLabel > 0 && len(stack) > 0 {
+				stack[len(stack)-1].Title += string(t)
+			}
+		}
+	}
+
+	return navs, nil
+}
+
+// parseNCX reads the navigation map of an EPUB2 NCX document and returns its
+// entries.
+func parseNCX(r io.Reader) ([]navEntry, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+
+	var (
+		stack   []*navEntry // open navigation points
+		top     []navEntry  // completed top-level entries
+		inLabel bool        // within the text of a point's label
+	)
+
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "navPoint":
+				stack = append(stack, new(navEntry))
+			case "text":
+				// Only the first label of each point is used.
+				if len(stack) > 0 && stack[len(stack)-1].Title == "" {
+					inLabel = true
+				}
+			case "content":
+				if len(stack) > 0 && stack[len(stack)-1].HREF == "" {
+					stack[len(stack)-1].HREF = attr(t, "src")
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "navPoint":
+				if len(stack) > 0 {
+					e := stack[len(stack)-1]
+					e.Title = strings.Join(strings.Fields(e.Title), " ")
+					stack = stack[:len(stack)-1]
+					if len(stack) > 0 {
+						parent := stack[len(stack)-1]
+						parent.Children = append(parent.Children, *e)
+					} else {
+						top = append(top, *e)
+					}
+				}
+			case "text":
+				inLabel = false
+			}
+		case xml.CharData:
+			if inLabel && len(stack) > 0 {
+				stack[len(stack)-1].Title += string(t)
+			}
+		}
+	}
+
+	return top, nil
+}
+
+// resolveHREF resolves href, found in the document at base, relative to the
+// package document. Both base and the result are relative to the package
+// document.
+func resolveHREF(base, href string) string {
+	if strings.Contains(href, "://") {
+		return href
+	}
+
+	file, fragment := href, ""
+	if i := strings.Index(href, "#"); i >= 0 {
+		file, fragment = href[:i], href[i:]
+	}
+	if file == "" {
+		return base + fragment
+	}
+
+	return path.Join(path.Dir(base), file) + fragment
+}
+
+// navItem returns the package's EPUB3 navigation document, if any.
+func (p *Package) navItem() *Item {
+	for i := range p.Manifest.Items {
+		item := &p.Manifest.Items[i]
+		for _, prop := range strings.Fields(item.Properties) {
+			if prop == "nav" {
+				return item
+			}
+		}
+	}
+
+	return nil
+}
+
+// ncxMediaType is the media type of EPUB2 NCX documents.
+const ncxMediaType = "application/x-dtbncx+xml"
+
+// ncxItem returns the package's EPUB2 NCX document, if any: the item named by
+// the spine's toc attribute or, failing that, the first with the NCX media
+// type.
+func (p *Package) ncxItem() *Item {
+	for i := range p.Manifest.Items {
+		if item := &p.Manifest.Items[i]; p.ncxID != "" && item.ID == p.ncxID {
+			return item
+		}
+	}
+	for i := range p.Manifest.Items {
+		if item := &p.Manifest.Items[i]; item.MediaType == ncxMediaType {
+			return item
+		}
+	}
+
+	return nil
+}
+
+// setNav populates each rootfile's landmarks and table of contents from its
+// EPUB3 navigation document. Landmarks fall back to the EPUB2 guide, and the
+// table of contents to the EPUB2 NCX, when the navigation document has none.
+func (r *Reader) setNav() error {
+	for _, rf := range r.Container.Rootfiles {
+		rf.Landmarks, rf.TOC = nil, nil
+
+		if item := rf.navItem(); item != nil && item.f != nil {
+			f, err := item.Open()
+			if err != nil {
+				return err
+			}
+			navs, err := parseNav(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("epub: parsing %s: %w", item.HREF, err)
+			}
+
+			for _, e := range navs["landmarks"] {
+				rf.Landmarks = append(rf.Landmarks, Landmark{
+					Type:  e.Type,
+					Title: e.Title,
+					HREF:  resolveHREF(item.HREF, e.HREF),
+				})
+			}
+			rf.TOC = tocEntries(item.HREF, navs["toc"], 0)
+		}
+
+		if item := rf.ncxItem(); len(rf.TOC) == 0 && item != nil && item.f != nil {
+			f, err := item.Open()
+			if err != nil {
+				return err
+			}
+			entries, err := parseNCX(f)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("epub: parsing %s: %w", item.HREF, err)
+			}
+			rf.TOC = tocEntries(item.HREF, entries, 0)
+		}
+
+		if len(rf.Landmarks) > 0 {
+			continue
+		}
+		for _, ref := range rf.Guide.References {
+			rf.Landmarks = append(rf.Landmarks, Landmark{
+				Type:  ref.Type,
+				Title: ref.Title,
+				HREF:  ref.HREF,
+			})
+		}
+	}
+
+	return nil
+}
+
+// tocEntries flattens nested nav entries, found in the document at base, into
+// table of contents entries, each parent followed by its children.
+func tocEntries(base string, entries []navEntry, depth int) []TOCEntry {
+	var toc []TOCEntry
+	for _, e := range entries {
+		toc = append(toc, TOCEntry{
+			Title: e.Title,
+			HREF:  resolveHREF(base, e.HREF),
+			Depth: depth,
+		})
+		toc = append(toc, tocEntries(base, e.Children, depth+1)...)
+	}
+
+	return toc
+}
+
+// Landmark returns the first landmark with one of the given types. It returns
+// false if there is none.
+func (p *Package) Landmark(types ...string) (Landmark, bool) {
+	for _, t := range types {
+		for _, l := range p.Landmarks {
+			if strings.EqualFold(l.Type, t) {
+				return l, true
+			}
+		}
+	}
+
+	return Landmark{}, false
+}
+
+// SpineIndex returns the position in the spine of the item at href, which is
+// relative to the package document and may include a fragment identifier. It
+// returns false if the item is not in the spine.
+func (p *Package) SpineIndex(href string) (int, bool) {
+	if i := strings.Index(href, "#"); i >= 0 {
+		href = href[:i]
+	}
+
+	for i, itemref := range p.Spine.Itemrefs {
+		if itemref.Item != nil && path.Clean(itemref.HREF) == path.Clean(href) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
@@ -1,36 +1,101 @@
 package main
 
 import (
-	"archive/zip"
+	"flag"
 	"fmt"
 	"os"
-
-	"github.com/taylorskalyo/goreader/epub"
 )
 
 func main() {
-	if len(os.Args) <= 1 {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load config: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	splitLevel := flag.Int("split", cfg.Split, "split chapters into sections at headings up to `level` (1-6)")
+	showStats := flag.Bool("stats", false, "print a summary of logged reading sessions and exit")
+	cat := flag.Bool("cat", false, "write the book to stdout as text and exit")
+	catColumns := flag.Int("width", 0, "wrap text written by -cat at `columns` (default: the terminal width or max_line_width)")
+	theme := flag.String("theme", cfg.Theme, "display text with the theme called `name`, built in or defined in a theme file")
+	noColor := flag.Bool("no-color", cfg.NoColor, "display text without colors, using only attributes such as bold")
+	lint := flag.Bool("lint", false, "report problems found in the book's chapters and exit, with status 1 if there are any")
+	flag.Parse()
+	cfg.NoColor = *noColor
+
+	if *showStats {
+		if err := printStats(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to read stats: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.NArg() < 1 {
 		fmt.Fprintln(os.Stderr, "You must specify a file")
 		os.Exit(1)
 	}
 
-	rc, err := epub.OpenReader(os.Args[1])
+	b, err := openBook(flag.Arg(0))
 	if err != nil {
-		var msg string
-		switch err {
-		case zip.ErrFormat, zip.ErrAlgorithm, zip.ErrChecksum:
-			msg = fmt.Sprintf("cannot unzip contents: %s", err.Error())
-		default:
-			msg = err.Error()
-		}
-		fmt.Fprintf(os.Stderr, "Unable to open epub: %s\n", msg)
+		fmt.Fprintf(os.Stderr, "Unable to open book: %s\n", err.Error())
 		os.Exit(1)
 	}
-	defer rc.Close()
-	book := rc.Rootfiles[0]
 
-	a := app{book: book}
-	if err := a.run(); err != nil {
+	// Missing or unreadable state is not fatal; the book opens with global
+	// settings.
+	st, _ := loadState()
+	a := app{
+		queue:  flag.Args(),
+		config: cfg,
+		state:  st,
+	}
+
+	// Options given on the command line take precedence over saved settings.
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "split":
+			a.split = splitLevel
+		case "theme":
+			if _, err := loadTheme(*theme); err != nil {
+				fmt.Fprintf(os.Stderr, "Unable to load theme: %s\n", err.Error())
+				os.Exit(1)
+			}
+			a.themeName = theme
+		}
+	})
+	a.setBook(0, b)
+
+	if *cat {
+		err = a.cat(os.Stdout, *catColumns)
+		a.src.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *lint {
+		n := a.lint(os.Stdout)
+		a.src.Close()
+		if n > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if cfg.Stats {
+		a.stats = newStatsLogger()
+	}
+
+	err = a.run()
+	if a.stats != nil {
+		a.stats.close()
+	}
+	a.src.Close()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"slices"
+	"time"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/taylorskalyo/goreader/render"
+)
+
+// maxHeadingLevel is the deepest heading level, that of <h6>.
+const maxHeadingLevel = 6
+
+// keySequenceDelay is how long a second press of [ or ] is waited for, after
+// the first, before the first is taken as a press on its own.
+const keySequenceDelay = 400 * time.Millisecond
+
+// bracketKey handles a press of [ or ]. Pressed once, they move to the
+// previous or next book; pressed twice in quick succession, to the previous
+// or next heading.
+func (a *app) bracketKey(ch rune) error {
+	if a.prefix == ch {
+		a.stopPrefix()
+		return a.jumpHeading(ch == ']')
+	}
+	if err := a.flushPrefix(); err != nil {
+		return err
+	}
+
+	a.prefix, a.prefixed = ch, time.Now()
+	a.prefixTimer = time.AfterFunc(keySequenceDelay, termbox.Interrupt)
+	return nil
+}
+
+// flushPrefix takes a pending press of [ or ] as a press on its own, which
+// moves to the previous or next book.
+func (a *app) flushPrefix() error {
+	ch := a.prefix
+	a.stopPrefix()
+	switch {
+	case ch == ']' && a.current < len(a.queue)-1:
+		return a.switchBook(a.current + 1)
+	case ch == '[' && a.current > 0:
+		return a.switchBook(a.current - 1)
+	}
+
+	return nil
+}
+
+// expirePrefix calls flushPrefix once a second press of a pending [ or ] is
+// no longer waited for.
+func (a *app) expirePrefix() error {
+	if a.prefix == 0 || time.Since(a.prefixed) < keySequenceDelay {
+		return nil
+	}
+
+	return a.flushPrefix()
+}
+
+// stopPrefix forgets a pending press of [ or ], and cancels the wake up
+// arranged for it.
+func (a *app) stopPrefix() {
+	a.prefix = 0
+	if a.prefixTimer != nil {
+		a.prefixTimer.Stop()
+		a.prefixTimer = nil
+	}
+}
+
+// jumpHeading moves to the next heading below the top of the screen, or the
+// previous one above it, at or above the configured heading level. When the
+// current chapter has none left, it moves to the first heading of the next
+// chapter that has one, or the last heading of the previous one.
+func (a *app) jumpHeading(forward bool) error {
+	level := a.config.HeadingLevel
+	if forward && a.pager.nextHeading(level) || !forward && a.pager.prevHeading(level) {
+		return nil
+	}
+
+	step := 1
+	if !forward {
+		step = -1
+	}
+	opts := a.renderOptions()
+	for i := a.chapter + step; i >= 0 && i < len(a.book.Spine.Itemrefs); i += step {
+		doc, err := a.parseChapter(i, opts)
+		if err != nil || !hasHeading(doc.Headings, level) {
+			continue
+		}
+
+		prev := a.pager.doc
+		a.chapter = i
+		if err := a.openChapter(); err != nil {
+			return err
+		}
+		if forward {
+			a.pager.scrollY = -1
+			a.pager.nextHeading(level)
+		} else {
+			a.pager.scrollY = a.pager.doc.Rows()
+			a.pager.prevHeading(level)
+		}
+		a.transition(prev, forward)
+		return nil
+	}
+
+	a.message = "No more headings"
+	return nil
+}
+
+// hasHeading reports whether any of headings is at or above level.
+func hasHeading(headings []render.Heading, level int) bool {
+	return slices.ContainsFunc(headings, func(h render.Heading) bool {
+		return h.Level <= level
+	})
+}
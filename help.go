@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// helpLines returns the lines of the help overlay: each category's name,
+// followed by its bindings with their keys aligned in a column.
+func helpLines() []string {
+	categories := keyBindings()
+	width := 0
+	for _, c := range categories {
+		for _, b := range c.bindings {
+			width = max(width, len([]rune(b.keysLabel())))
+		}
+	}
+
+	var lines []string
+	for i, c := range categories {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, c.name)
+		for _, b := range c.bindings {
+			lines = append(lines, fmt.Sprintf("  %-*s  %s", width, b.keysLabel(), b.action))
+		}
+	}
+
+	return lines
+}
+
+// help displays the key bindings over the pager until a key other than those
+// that scroll it is pressed.
+func (a *app) help() error {
+	m := &menu{title: "Keys", entries: helpLines(), readOnly: true}
+	m.move(0)
+	a.menu = m
+	defer func() { a.menu = nil }()
+	for {
+		if err := a.draw(); err != nil {
+			return err
+		}
+
+		_, _, _, h := m.bounds()
+		rows := max(h-2, 1)
+		switch ev := termbox.PollEvent(); ev.Type {
+		case termbox.EventKey:
+			switch {
+			case ev.Key == termbox.KeyArrowDown || ev.Ch == 'j':
+				m.scroll(1)
+			case ev.Key == termbox.KeyArrowUp || ev.Ch == 'k':
+				m.scroll(-1)
+			case ev.Key == termbox.KeyPgdn || ev.Key == termbox.KeySpace || ev.Ch == 'f':
+				m.scroll(rows)
+			case ev.Key == termbox.KeyPgup || ev.Ch == 'b':
+				m.scroll(-rows)
+			default:
+				return nil
+			}
+		case termbox.EventError:
+			return ev.Err
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// errQuit is returned by the action of a key that ends the run loop.
+var errQuit = errors.New("quit")
+
+// binding is an action and the keys that run it. keys are characters or the
+// names of special keys (see keyName), and are shown in the help overlay
+// along with the action's description. run is called with the key that was
+// pressed, so that keys for opposite actions (e.g. next and previous page)
+// may share a binding.
+type binding struct {
+	keys   []string
+	action string
+	run    func(a *app, key string) error
+}
+
+// keyCategory is a group of related bindings.
+type keyCategory struct {
+	name     string
+	bindings []binding
+}
+
+// do adapts an action that does not depend on the key pressed to a binding's
+// run function.
+func do(f func(*app) error) func(*app, string) error {
+	return func(a *app, _ string) error {
+		return f(a)
+	}
+}
+
+// keyBindings returns the keys the run loop handles, by category, as the help
+// overlay lists them. It is a function rather than a variable since the help
+// overlay is one of its actions.
+func keyBindings() []keyCategory {
+	return []keyCategory{
+		{"Navigation", []binding{
+			{[]string{"j", "Down"}, "Scroll down", do((*app).scrollDown)},
+			{[]string{"k", "Up"}, "Scroll up", do((*app).scrollUp)},
+			{[]string{"h", "Left"}, "Scroll left", func(a *app, _ string) error {
+				a.pager.scrollLeft()
+				return nil
+			}},
+			{[]string{"l", "Right"}, "Scroll right", func(a *app, _ string) error {
+				a.pager.scrollRight()
+				return nil
+			}},
+			{[]string{"f", "b"}, "Next / previous page", func(a *app, key string) error {
+				if key == "f" {
+					return a.pageForward()
+				}
+				return a.pageBack()
+			}},
+			{[]string{"L", "H"}, "Next / previous chapter or section", func(a *app, key string) error {
+				if key == "L" {
+					return a.sectionForward()
+				}
+				return a.sectionBack()
+			}},
+			{[]string{"g", "G"}, "Top / bottom of chapter", func(a *app, key string) error {
+				if key == "g" {
+					a.pager.toTop()
+				} else {
+					a.pager.toBottom()
+				}
+				return nil
+			}},
+			{[]string{"t"}, "Table of contents", do((*app).contentsMenu)},
+			{[]string{"M"}, "Landmarks", do((*app).landmarkMenu)},
+			{[]string{"P"}, "Pages of the print edition", do((*app).pageMenu)},
+			{[]string{"F"}, "Return to the furthest point read to", do((*app).toFurthest)},
+			{[]string{"/"}, "Search the book", do((*app).search)},
+			{[]string{"n", "N"}, "Next / previous match", func(a *app, key string) error {
+				return a.findNext(key == "n")
+			}},
+			{[]string{"m"}, "Bookmark this place", do((*app).addBookmark)},
+			{[]string{"B"}, "Bookmarks", do((*app).bookmarkMenu)},
+			{[]string{"Tab"}, "Select the next link", func(a *app, _ string) error {
+				a.nextLink()
+				return nil
+			}},
+			{[]string{"Enter"}, "Follow the selected link", do((*app).followLink)},
+			{[]string{"Backspace"}, "Go back to where the link was followed from", do((*app).goBack)},
+			{[]string{":"}, "Enter a command, e.g. L<n> to go to row n", do((*app).command)},
+		}},
+		{"Display", []binding{
+			{[]string{"i"}, "Toggle images", func(a *app, _ string) error {
+				a.settings.Images = !a.settings.Images
+				return a.reflow()
+			}},
+			{[]string{"W", "w"}, "Widen / narrow the maximum line width", func(a *app, key string) error {
+				step := lineWidthStep
+				if key == "w" {
+					step = -lineWidthStep
+				}
+				if a.settings.MaxLineWidth+step < minLineWidth {
+					return nil
+				}
+				a.settings.MaxLineWidth += step
+				return a.adjustLayout("Line width", a.settings.MaxLineWidth)
+			}},
+			{[]string{">", "<"}, "Widen / narrow margins", func(a *app, key string) error {
+				return a.adjustSetting("Margin", &a.settings.Margin, key == ">", maxMargin)
+			}},
+			{[]string{"+", "-"}, "Increase / decrease line spacing", func(a *app, key string) error {
+				return a.adjustSetting("Line spacing", &a.settings.LineSpacing, key == "+", maxLineSpacing)
+			}},
+			{[]string{")", "("}, "Increase / decrease image brightness", func(a *app, key string) error {
+				return a.adjustImage(&a.settings.Brightness, key == ")")
+			}},
+			{[]string{"}", "{"}, "Increase / decrease image contrast", func(a *app, key string) error {
+				return a.adjustImage(&a.settings.Contrast, key == "}")
+			}},
+			{[]string{"p"}, "Switch paragraph layout", func(a *app, _ string) error {
+				a.settings.Layout = a.settings.nextLayout()
+				return a.reflow()
+			}},
+			{[]string{"v"}, "Switch between scrolling and paged view", func(a *app, _ string) error {
+				a.settings.Paged = !a.settings.Paged
+				a.message = "Continuous scrolling"
+				if a.settings.Paged {
+					a.message = "Paged view"
+				}
+				return nil
+			}},
+			{[]string{"T"}, "Switch theme", do((*app).themeMenu)},
+			{[]string{"#"}, "Show or hide line numbers", func(a *app, _ string) error {
+				a.settings.LineNumbers = !a.settings.LineNumbers
+				return a.reflow()
+			}},
+			{[]string{"c"}, "Switch focus mode on or off", func(a *app, _ string) error {
+				a.settings.Focus = !a.settings.Focus
+				a.pager.focus = a.settings.Focus
+				return nil
+			}},
+			{[]string{"z"}, "Expand or collapse the section on screen", do((*app).toggleDetails)},
+			{[]string{"S"}, "Save display settings for this book", func(a *app, _ string) error {
+				a.saveSettings()
+				return nil
+			}},
+		}},
+		{"Book", []binding{
+			{[]string{"o"}, "Open the image or media on screen in the viewer", func(a *app, _ string) error {
+				a.openResource()
+				return nil
+			}},
+			{[]string{"a"}, "Show the title of an abbreviation on screen", func(a *app, _ string) error {
+				a.showTooltip()
+				return nil
+			}},
+			{[]string{"R"}, "Reload the book from disk", func(a *app, _ string) error {
+				a.reload()
+				return nil
+			}},
+			{[]string{"]", "["}, "Next / previous book; twice, next / previous heading", func(a *app, key string) error {
+				return a.bracketKey(rune(key[0]))
+			}},
+			{[]string{"?"}, "Show this help", do((*app).help)},
+			{[]string{"q", "Esc"}, "Quit", func(a *app, _ string) error {
+				done, err := a.closeBook()
+				if done && err == nil {
+					return errQuit
+				}
+				return err
+			}},
+		}},
+	}
+}
+
+// keyMap returns the bindings of keyBindings by the keys that run them.
+func keyMap() map[string]binding {
+	m := map[string]binding{}
+	for _, c := range keyBindings() {
+		for _, b := range c.bindings {
+			for _, key := range b.keys {
+				m[key] = b
+			}
+		}
+	}
+
+	return m
+}
+
+// keyNames are the names bindings give the special keys they are run by.
+var keyNames = map[termbox.Key]string{
+	termbox.KeyArrowDown:  "Down",
+	termbox.KeyArrowUp:    "Up",
+	termbox.KeyArrowLeft:  "Left",
+	termbox.KeyArrowRight: "Right",
+	termbox.KeyTab:        "Tab",
+	termbox.KeyEnter:      "Enter",
+	termbox.KeyBackspace:  "Backspace",
+	termbox.KeyBackspace2: "Backspace",
+	termbox.KeyEsc:        "Esc",
+}
+
+// keyName returns the name of the key pressed in a key event, as bindings
+// give it: the character typed, or the name of a special key. It returns an
+// empty string for keys without a name.
+func keyName(ev termbox.Event) string {
+	if ev.Ch != 0 {
+		return string(ev.Ch)
+	}
+
+	return keyNames[ev.Key]
+}
+
+// keysLabel returns the keys of a binding as the help overlay shows them.
+func (b binding) keysLabel() string {
+	return strings.Join(b.keys, " / ")
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"unicode/utf8"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+func TestKeyBindings(t *testing.T) {
+	bound := map[string]bool{}
+	for _, c := range keyBindings() {
+		for _, b := range c.bindings {
+			for _, key := range b.keys {
+				if bound[key] {
+					t.Errorf("Key %q is bound twice", key)
+				}
+				bound[key] = true
+
+				// Keys are characters or the names of special keys,
+				// so that a key press can run them.
+				if utf8.RuneCountInString(key) != 1 && !slices.Contains(slices.Collect(maps.Values(keyNames)), key) {
+					t.Errorf("Key %q is neither a character nor a special key", key)
+				}
+			}
+		}
+	}
+
+	testCases := []struct {
+		ev  termbox.Event
+		exp string
+	}{
+		{termbox.Event{Ch: 'j'}, "j"},
+		{termbox.Event{Key: termbox.KeyArrowDown}, "Down"},
+		{termbox.Event{Key: termbox.KeyBackspace2}, "Backspace"},
+		{termbox.Event{Key: termbox.KeyF1}, ""},
+	}
+	for _, tc := range testCases {
+		if name := keyName(tc.ev); name != tc.exp {
+			t.Errorf(expFormat, tc.exp, name)
+		}
+	}
+}
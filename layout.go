@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/taylorskalyo/goreader/render"
+)
+
+// adjustDelay is how long after a key press that changes the layout the text
+// is reflowed, when keys are pressed in quick succession.
+const adjustDelay = 100 * time.Millisecond
+
+// adjustLayout reflows the text after a key press changes the setting called
+// name to value, which is shown in the status bar.
+func (a *app) adjustLayout(name string, value int) error {
+	a.message = fmt.Sprintf("%s: %d", name, value)
+	return a.reflowSoon()
+}
+
+// adjustSetting raises the layout setting called name by one, or lowers it if
+// up is false, keeping it within [0, limit], and reflows the text if it
+// changed.
+func (a *app) adjustSetting(name string, value *int, up bool, limit int) error {
+	v := *value - 1
+	if up {
+		v = *value + 1
+	}
+	if v < 0 || v > limit {
+		return nil
+	}
+	*value = v
+
+	return a.adjustLayout(name, v)
+}
+
+// reflowSoon reflows the text at once, unless the last call was within
+// adjustDelay; it is then reflowed when the calls pause, so that holding a key
+// down or dragging the edge of a window lays the text out once rather than
+// for every event.
+func (a *app) reflowSoon() error {
+	now := time.Now()
+	defer func() { a.adjusted = now }()
+	if !a.pending && now.Sub(a.adjusted) >= adjustDelay {
+		return a.reflow()
+	}
+
+	a.pending = true
+	a.stopAdjust()
+	a.adjustTimer = time.AfterFunc(adjustDelay, termbox.Interrupt)
+
+	return nil
+}
+
+// flushLayout reflows the text for the layout changes held back by
+// reflowSoon, once they have paused.
+func (a *app) flushLayout() error {
+	if !a.pending || time.Since(a.adjusted) < adjustDelay {
+		return nil
+	}
+	a.pending = false
+
+	return a.reflow()
+}
+
+// stopAdjust cancels the wake up arranged by reflowSoon, if any.
+func (a *app) stopAdjust() {
+	if a.adjustTimer != nil {
+		a.adjustTimer.Stop()
+		a.adjustTimer = nil
+	}
+}
+
+// fitTerminal narrows the width opts lays text out at to that of the
+// terminal, less the gutter, when the terminal is narrower than the maximum
+// line width, so that lines are not cut off. Terminals too small to read in
+// are left to show that they are.
+func fitTerminal(opts *render.Options) {
+	if width, _ := viewSize(); width >= minViewWidth {
+		opts.Width = min(opts.Width, max(width-opts.Gutter, render.MinTextWidth))
+	}
+}
+
+// resize reflows the text when the terminal is resized to a width that changes
+// the width it is laid out at.
+func (a *app) resize() error {
+	opts := a.renderOptions()
+	opts.Gutter = a.pager.gutter
+	fitTerminal(&opts)
+	if opts.Width == a.pager.doc.Width-a.pager.gutter {
+		return nil
+	}
+
+	return a.reflowSoon()
+}
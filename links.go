@@ -0,0 +1,160 @@
+package main
+
+import "github.com/taylorskalyo/goreader/render"
+
+// maxHistory is the number of positions that following links remembers to
+// go back to.
+const maxHistory = 100
+
+// selectedLink returns the link selected in the pager's document, if any.
+func (p pager) selectedLink() (render.Link, bool) {
+	if p.link <= 0 || p.link > len(p.doc.Links) {
+		return render.Link{}, false
+	}
+
+	return p.doc.Links[p.link-1], true
+}
+
+// nextLink selects the next link in the chapter: the one after the selected
+// link, or failing that the first from the top of the viewport on, going
+// round to the first in the chapter after the last. The viewport is scrolled
+// to links that are not on screen, and the link's target shown in the status
+// bar.
+func (a *app) nextLink() {
+	links := a.pager.doc.Links
+	if len(links) == 0 {
+		a.message = "No links in this chapter"
+		return
+	}
+
+	_, viewHeight := viewSize()
+	visible := func(l render.Link) bool {
+		return l.End >= a.pager.scrollY && l.Row < a.pager.scrollY+viewHeight
+	}
+	next := 0
+	if l, ok := a.pager.selectedLink(); ok && visible(l) {
+		next = a.pager.link % len(links)
+	} else {
+		for i, l := range links {
+			if l.End >= a.pager.scrollY {
+				next = i
+				break
+			}
+		}
+	}
+	a.pager.link = next + 1
+
+	l := links[next]
+	if !visible(l) {
+		a.pager.toRow(l.Row)
+	}
+	a.message = l.Href
+}
+
+// followLink goes to the target of the selected link: an element of the
+// current chapter, or another chapter or an element of it. The position the
+// link was followed from is remembered, so that goBack can return to it.
+// Links out of the book are only shown in the status bar.
+func (a *app) followLink() error {
+	l, ok := a.pager.selectedLink()
+	if !ok {
+		a.message = "No link selected; press Tab to select one"
+		return nil
+	}
+
+	a.visit(l)
+	from := position{Chapter: a.chapter, Row: a.pager.scrollY}
+	switch {
+	case l.Local():
+	case l.Item != "":
+		i, ok := a.book.SpineIndex(l.Item)
+		if !ok {
+			a.message = "Link is to a document outside the reading order: " + l.Href
+			return nil
+		}
+		if i != a.chapter {
+			a.chapter = i
+			if err := a.openChapter(); err != nil {
+				return err
+			}
+		}
+	default:
+		a.message = "Link is to outside the book: " + l.Href
+		return nil
+	}
+
+	if len(a.history) >= maxHistory {
+		a.history = a.history[1:]
+	}
+	a.history = append(a.history, from)
+	a.pager.link = 0
+	if row, ok := a.pager.doc.IDs[l.Fragment]; ok {
+		a.pager.toRow(row)
+	} else {
+		a.pager.toTop()
+	}
+
+	return nil
+}
+
+// linkKey returns what a link points to, so that links to the same target
+// from different chapters are told to be the same: the href of the document
+// in the book and the id within it, or failing that the link's href.
+func (a *app) linkKey(l render.Link) string {
+	item := l.Item
+	if l.Local() {
+		if ref := a.book.Spine.Itemrefs[a.chapter]; ref.Item != nil {
+			item = ref.HREF
+		}
+	}
+	if item == "" {
+		return l.Href
+	}
+
+	return item + "#" + l.Fragment
+}
+
+// visit remembers that a link has been followed, so that it and the other
+// links to its target are drawn as visited until another book is opened.
+func (a *app) visit(l render.Link) {
+	if a.visited == nil {
+		a.visited = map[string]bool{}
+	}
+	a.visited[a.linkKey(l)] = true
+	a.markVisited()
+}
+
+// markVisited marks which of the chapter's links have been followed.
+func (a *app) markVisited() {
+	a.pager.visited = make([]bool, len(a.pager.doc.Links))
+	for i, l := range a.pager.doc.Links {
+		a.pager.visited[i] = a.visited[a.linkKey(l)]
+	}
+}
+
+// goBack returns to the position the last link followed was followed from.
+func (a *app) goBack() error {
+	n := len(a.history)
+	if n == 0 {
+		a.message = "No link to go back from"
+		return nil
+	}
+	pos := a.history[n-1]
+	a.history = a.history[:n-1]
+
+	// The book may have lost chapters since it was reloaded.
+	if pos.Chapter >= len(a.book.Spine.Itemrefs) {
+		a.message = "The chapter the link was followed from is gone"
+		return nil
+	}
+	if pos.Chapter != a.chapter {
+		a.chapter = pos.Chapter
+		if err := a.openChapter(); err != nil {
+			return err
+		}
+	}
+	a.pager.link = 0
+	a.pager.toRow(pos.Row)
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/taylorskalyo/goreader/epub"
+	"github.com/taylorskalyo/goreader/render"
+)
+
+// lint parses every chapter of every book in the queue, as the pager would,
+// and writes the problems found in their sources to w, followed by a summary.
+// It returns the number of problems found.
+func (a *app) lint(w io.Writer) int {
+	problems, chapters, faulty := 0, 0, 0
+	for i, name := range a.queue {
+		if i != a.current {
+			b, err := openBook(name)
+			if err != nil {
+				fmt.Fprintf(w, "%s: %s\n", name, err)
+				problems++
+				continue
+			}
+			a.setBook(i, b)
+		}
+
+		opts := a.settings.renderOptions()
+		opts.Lint = true
+		for _, itemref := range a.book.Spine.Itemrefs {
+			n := lintItem(w, name, itemref, a.book.Manifest.Items, opts)
+			problems += n
+			chapters++
+			if n > 0 {
+				faulty++
+			}
+		}
+	}
+
+	if problems == 0 {
+		fmt.Fprintf(w, "No problems found in %s\n", count(chapters, "chapter"))
+	} else {
+		fmt.Fprintf(w, "%s found in %d of %s\n", count(problems, "problem"), faulty, count(chapters, "chapter"))
+	}
+
+	return problems
+}
+
+// count returns n followed by noun, made plural unless n is one.
+func count(n int, noun string) string {
+	if n != 1 {
+		noun += "s"
+	}
+
+	return fmt.Sprintf("%d %s", n, noun)
+}
+
+// lintItem parses a chapter and writes the problems found in its source to
+// w, each preceded by the book's name and the chapter's path. It returns the
+// number of problems found.
+func lintItem(w io.Writer, name string, itemref epub.Itemref, items []epub.Item, opts render.Options) int {
+	prefix := name + ": " + itemref.HREF + ": "
+
+	f, err := itemref.Open()
+	if err != nil {
+		fmt.Fprintf(w, "%s%s\n", prefix, err)
+		return 1
+	}
+	defer f.Close()
+
+	doc, err := render.Parse(f, items, opts)
+	for _, pr := range doc.Problems {
+		fmt.Fprintf(w, "%s%s\n", prefix, pr)
+	}
+	if err != nil {
+		fmt.Fprintf(w, "%s%s\n", prefix, err)
+		return len(doc.Problems) + 1
+	}
+
+	return len(doc.Problems)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// menu is a list of entries displayed over the pager, from which one may be
+// chosen.
+type menu struct {
+	title    string
+	entries  []string
+	selected int
+
+	// offset is the index of the first visible entry.
+	offset int
+
+	// current, when markCurrent is set, is the index of the entry for the
+	// reader's position, which is shown in bold.
+	current     int
+	markCurrent bool
+
+	// filterable menus are narrowed to the entries containing query as it
+	// is typed. shown holds the indices of the entries that match; selected
+	// and offset index into it.
+	filterable bool
+	query      string
+	shown      []int
+
+	// readOnly menus are only read, such as the help overlay, and show no
+	// selected entry.
+	readOnly bool
+}
+
+// filter narrows the shown entries to those containing the query, ignoring
+// case, keeping the selected entry where it still matches.
+func (m *menu) filter() {
+	prev := -1
+	if m.selected < len(m.shown) {
+		prev = m.shown[m.selected]
+	}
+
+	q := strings.ToLower(m.query)
+	m.shown = m.shown[:0]
+	m.selected, m.offset = 0, 0
+	for i, e := range m.entries {
+		if strings.Contains(strings.ToLower(e), q) {
+			if i == prev {
+				m.selected = len(m.shown)
+			}
+			m.shown = append(m.shown, i)
+		}
+	}
+	m.move(0)
+}
+
+// bounds returns the position and size of a menu's box, including its
+// border, centered in the terminal.
+func (m *menu) bounds() (x, y, w, h int) {
+	termWidth, termHeight := termbox.Size()
+
+	// The title is padded with a space on either side. Filterable menus are
+	// sized to fit all their entries, so they do not shrink while typing.
+	w = len([]rune(m.heading())) + 6
+	for _, e := range m.entries {
+		if n := len([]rune(e)) + 4; n > w {
+			w = n
+		}
+	}
+	if w > termWidth-4 {
+		w = termWidth - 4
+	}
+
+	h = len(m.entries) + 2
+	if h > termHeight-4 {
+		h = termHeight - 4
+	}
+
+	return (termWidth - w) / 2, (termHeight - h) / 2, w, h
+}
+
+// draw displays the menu centered in the terminal.
+func (m *menu) draw() {
+	x0, y0, w, h := m.bounds()
+	if w < 4 || h < 3 {
+		return
+	}
+	fg, bg := termbox.ColorDefault, termbox.ColorDefault
+
+	// Border, with the title set into the top edge.
+	for x := x0; x < x0+w; x++ {
+		termbox.SetCell(x, y0, '─', fg, bg)
+		termbox.SetCell(x, y0+h-1, '─', fg, bg)
+	}
+	for y := y0; y < y0+h; y++ {
+		termbox.SetCell(x0, y, '│', fg, bg)
+		termbox.SetCell(x0+w-1, y, '│', fg, bg)
+	}
+	termbox.SetCell(x0, y0, '┌', fg, bg)
+	termbox.SetCell(x0+w-1, y0, '┐', fg, bg)
+	termbox.SetCell(x0, y0+h-1, '└', fg, bg)
+	termbox.SetCell(x0+w-1, y0+h-1, '┘', fg, bg)
+	printText(x0+2, y0, w-4, " "+m.heading()+" ", fg|termbox.AttrBold, bg)
+
+	rows := h - 2
+	for i := 0; i < rows; i++ {
+		y := y0 + 1 + i
+		efg := fg
+		if !m.readOnly && m.offset+i == m.selected && m.offset+i < len(m.shown) {
+			efg |= termbox.AttrReverse
+		}
+		for x := x0 + 1; x < x0+w-1; x++ {
+			termbox.SetCell(x, y, ' ', efg, bg)
+		}
+		if m.offset+i < len(m.shown) {
+			entry := m.shown[m.offset+i]
+			if m.markCurrent && entry == m.current {
+				efg |= termbox.AttrBold
+			}
+			printText(x0+2, y, w-4, m.entries[entry], efg, bg)
+		}
+	}
+}
+
+// heading returns the title of the menu, followed by the query being typed
+// into filterable menus.
+func (m *menu) heading() string {
+	if m.filterable && m.query != "" {
+		return m.title + ": " + m.query
+	}
+
+	return m.title
+}
+
+// move changes the selected entry by n, scrolling to keep it visible.
+func (m *menu) move(n int) {
+	if m.shown == nil {
+		m.shown = make([]int, len(m.entries))
+		for i := range m.shown {
+			m.shown[i] = i
+		}
+	}
+	m.selected = clamp(m.selected+n, 0, len(m.shown)-1)
+	if m.selected < 0 {
+		m.selected = 0
+	}
+
+	_, _, _, h := m.bounds()
+	rows := h - 2
+	if rows < 1 {
+		rows = 1
+	}
+	if m.selected < m.offset {
+		m.offset = m.selected
+	} else if m.selected >= m.offset+rows {
+		m.offset = m.selected - rows + 1
+	}
+}
+
+// scroll moves the visible entries of a read-only menu by n, keeping the
+// last entry at or below the bottom of the box.
+func (m *menu) scroll(n int) {
+	_, _, _, h := m.bounds()
+	m.offset = clamp(m.offset+n, 0, max(len(m.shown)-(h-2), 0))
+}
+
+// printText displays str starting at x, y, truncated to width w.
+func printText(x, y, w int, str string, fg, bg termbox.Attribute) {
+	for i, r := range []rune(str) {
+		if i >= w {
+			break
+		}
+		termbox.SetCell(x+i, y, r, fg, bg)
+	}
+}
+
+// runMenu displays m over the pager until an entry is chosen or the menu is
+// dismissed. It returns the index of the chosen entry, or -1 if the menu was
+// dismissed. Typing into a filterable menu narrows its entries rather than
+// moving the selection.
+func (a *app) runMenu(m *menu) (int, error) {
+	if len(m.entries) == 0 {
+		return -1, nil
+	}
+
+	m.move(0)
+	a.menu = m
+	defer func() { a.menu = nil }()
+	for {
+		if err := a.draw(); err != nil {
+			return -1, err
+		}
+
+		_, _, _, h := m.bounds()
+		switch ev := termbox.PollEvent(); ev.Type {
+		case termbox.EventKey:
+			switch ev.Key {
+			case termbox.KeyEsc:
+				return -1, nil
+			case termbox.KeyEnter:
+				if len(m.shown) == 0 {
+					continue
+				}
+				return m.shown[m.selected], nil
+			case termbox.KeyArrowDown:
+				m.move(1)
+			case termbox.KeyArrowUp:
+				m.move(-1)
+			case termbox.KeyPgdn:
+				m.move(h - 2)
+			case termbox.KeyPgup:
+				m.move(2 - h)
+			case termbox.KeyBackspace, termbox.KeyBackspace2:
+				if m.filterable && m.query != "" {
+					q := []rune(m.query)
+					m.query = string(q[:len(q)-1])
+					m.filter()
+				}
+			case termbox.KeySpace:
+				if m.filterable {
+					m.query += " "
+					m.filter()
+				}
+			default:
+				if m.filterable && ev.Ch != 0 {
+					m.query += string(ev.Ch)
+					m.filter()
+					continue
+				}
+				switch ev.Ch {
+				case 'q':
+					return -1, nil
+				case 'j':
+					m.move(1)
+				case 'k':
+					m.move(-1)
+				case 'g':
+					m.move(-len(m.entries))
+				case 'G':
+					m.move(len(m.entries))
+				}
+			}
+		case termbox.EventError:
+			return -1, ev.Err
+		}
+	}
+}
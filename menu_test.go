@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestMenuFilter(t *testing.T) {
+	m := &menu{entries: []string{"Down the Rabbit-Hole", "The Pool of Tears", "A Caucus-Race", "The Rabbit Sends in a Little Bill"}}
+	m.selected = 3
+	m.move(0)
+
+	testCases := []struct {
+		query    string
+		shown    []int
+		selected int
+	}{
+		{"rabbit", []int{0, 3}, 1},
+		{"rabbit-", []int{0}, 0},
+		{"rabbit-x", []int{}, 0},
+		{"", []int{0, 1, 2, 3}, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			m.query = tc.query
+			m.filter()
+			if len(m.shown) != len(tc.shown) {
+				t.Fatalf(expFormat, tc.shown, m.shown)
+			}
+			for i := range tc.shown {
+				if m.shown[i] != tc.shown[i] {
+					t.Errorf(expFormat, tc.shown, m.shown)
+				}
+			}
+			if m.selected != tc.selected {
+				t.Errorf(expFormat, tc.selected, m.selected)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// nightShift dims the display between two local times of day, given as
+// "HH:MM". It is disabled unless both times are set.
+type nightShift struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	// Brightness is added to the brightness of images while the night shift
+	// is active.
+	Brightness int `json:"brightness"`
+}
+
+// enabled reports whether a schedule has been configured.
+func (n nightShift) enabled() bool {
+	return n.Start != "" && n.End != ""
+}
+
+// validate checks that the schedule's times are well formed.
+func (n nightShift) validate() error {
+	if !n.enabled() {
+		return nil
+	}
+	for _, s := range []string{n.Start, n.End} {
+		if _, err := clockMinutes(s); err != nil {
+			return fmt.Errorf("night: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// active reports whether the night shift applies at t. Schedules that end
+// before they start span midnight.
+func (n nightShift) active(t time.Time) bool {
+	if !n.enabled() {
+		return false
+	}
+	start, err := clockMinutes(n.Start)
+	if err != nil {
+		return false
+	}
+	end, err := clockMinutes(n.End)
+	if err != nil {
+		return false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+
+	return now >= start || now < end
+}
+
+// untilChange returns how long after t the night shift next starts or ends.
+// It returns false if no schedule is configured.
+func (n nightShift) untilChange(t time.Time) (time.Duration, bool) {
+	if !n.enabled() {
+		return 0, false
+	}
+	start, err := clockMinutes(n.Start)
+	if err != nil {
+		return 0, false
+	}
+	end, err := clockMinutes(n.End)
+	if err != nil {
+		return 0, false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	wait := 24 * 60
+	for _, m := range []int{start, end} {
+		if d := (m - now + 24*60) % (24 * 60); d > 0 && d < wait {
+			wait = d
+		}
+	}
+
+	return t.Truncate(time.Minute).Add(time.Duration(wait) * time.Minute).Sub(t), true
+}
+
+// clockMinutes parses a time of day such as "22:30" into minutes since
+// midnight.
+func clockMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
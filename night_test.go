@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+const expFormat = "Expected: %v, but got: %v\n"
+
+func TestNightShiftActive(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 1, 1, hour, min, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		name  string
+		night nightShift
+		t     time.Time
+		exp   bool
+	}{
+		{"disabled", nightShift{}, at(23, 0), false},
+		{"before evening window", nightShift{Start: "18:00", End: "21:00"}, at(17, 59), false},
+		{"in evening window", nightShift{Start: "18:00", End: "21:00"}, at(18, 0), true},
+		{"at evening window end", nightShift{Start: "18:00", End: "21:00"}, at(21, 0), false},
+		{"overnight before midnight", nightShift{Start: "22:00", End: "07:00"}, at(23, 30), true},
+		{"overnight after midnight", nightShift{Start: "22:00", End: "07:00"}, at(6, 59), true},
+		{"overnight morning", nightShift{Start: "22:00", End: "07:00"}, at(7, 0), false},
+		{"overnight afternoon", nightShift{Start: "22:00", End: "07:00"}, at(12, 0), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.night.active(tc.t); got != tc.exp {
+				t.Errorf(expFormat, tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestNightShiftValidate(t *testing.T) {
+	tests := []struct {
+		night nightShift
+		exp   bool
+	}{
+		{nightShift{}, true},
+		{nightShift{Start: "22:00", End: "07:00"}, true},
+		{nightShift{Start: "22:00", End: "7am"}, false},
+		{nightShift{Start: "25:00", End: "07:00"}, false},
+	}
+
+	for _, tc := range tests {
+		if got := tc.night.validate() == nil; got != tc.exp {
+			t.Errorf(expFormat, tc.exp, got)
+		}
+	}
+}
+
+func TestNightShiftUntilChange(t *testing.T) {
+	at := func(hour, min, sec int) time.Time {
+		return time.Date(2024, 1, 1, hour, min, sec, 0, time.Local)
+	}
+	night := nightShift{Start: "22:00", End: "07:00"}
+
+	tests := []struct {
+		name  string
+		night nightShift
+		t     time.Time
+		exp   time.Duration
+		ok    bool
+	}{
+		{"disabled", nightShift{}, at(23, 0, 0), 0, false},
+		{"before start", night, at(21, 30, 0), 30 * time.Minute, true},
+		{"within minute", night, at(21, 59, 45), 15 * time.Second, true},
+		{"at start", night, at(22, 0, 0), 9 * time.Hour, true},
+		{"after midnight", night, at(6, 0, 0), time.Hour, true},
+		{"same times", nightShift{Start: "07:00", End: "07:00"}, at(7, 0, 0), 24 * time.Hour, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d, ok := tc.night.untilChange(tc.t)
+			if d != tc.exp || ok != tc.ok {
+				t.Errorf(expFormat, tc.exp, d)
+			}
+		})
+	}
+}
@@ -1,42 +1,224 @@
 package main
 
-import termbox "github.com/nsf/termbox-go"
+import (
+	"fmt"
+	"strconv"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/taylorskalyo/goreader/render"
+)
+
+// statusBarHeight is the number of terminal rows reserved below the pager for
+// the status bar.
+const statusBarHeight = 1
+
+// minViewWidth and minViewHeight are the size of the smallest viewport the
+// pager is drawn in.
+const (
+	minViewWidth  = 10
+	minViewHeight = 1
+)
 
 type pager struct {
 	scrollX int
 	scrollY int
-	doc     cellbuf
+	doc     render.Document
+
+	// dim, when set, draws the document's text with reduced intensity.
+	// focus, when set, does so for all but the paragraph at the top of the
+	// viewport.
+	dim   bool
+	focus bool
+
+	// bg is the color drawn behind text that does not set its own, and
+	// theme the theme whose roles highlights are drawn with.
+	bg    termbox.Attribute
+	theme render.Theme
+
+	// lead, when set, holds rows from the end of the previous chapter, which
+	// are drawn dimmed above the document while it is scrolled to the top.
+	// Scrolling down scrolls them away before the document.
+	lead [][]termbox.Cell
+
+	// gutter, when set, is the width of the columns the document leaves
+	// before its left margin for line numbers. The document's first row is
+	// numbered firstLine.
+	gutter    int
+	firstLine int
+
+	// link is one more than the index in doc.Links of the selected link,
+	// or zero if no link is selected. visited holds whether each of
+	// doc.Links has been followed.
+	link    int
+	visited []bool
+
+	// matches are the matches of the search in doc, and match is one more
+	// than the index of the current one, or zero if there is none.
+	matches []render.Match
+	match   int
 }
 
-// draw displays a pager's cell buffer in the terminal.
-func (p pager) draw() error {
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+// highlight is a run of a row's cells, from column from up to column to, that
+// is drawn with an attribute laid over that of its text (see render.Overlay).
+type highlight struct {
+	from, to int
+	attr     termbox.Attribute
+}
+
+// highlights returns the runs of row that are highlighted: those of the
+// visited links, the search matches and the selected link, in the order they
+// are laid over the text.
+func (p pager) highlights(row int) []highlight {
+	var hs []highlight
+	add := func(startRow, col, endRow, endCol int, attr termbox.Attribute) {
+		if from, to := p.span(row, startRow, col, endRow, endCol); from < to {
+			hs = append(hs, highlight{from, to, attr})
+		}
+	}
+	for i, l := range p.doc.Links {
+		if l.Row > row {
+			break
+		}
+		if i < len(p.visited) && p.visited[i] {
+			add(l.Row, l.Col, l.End, l.EndCol, p.theme.VisitedLink)
+		}
+	}
+	for i, m := range p.matches {
+		if m.Row > row {
+			break
+		}
+		attr := p.theme.SearchMatch
+		if i == p.match-1 {
+			attr = p.theme.SearchCurrent
+		}
+		add(m.Row, m.Col, m.End, m.EndCol, attr)
+	}
+	if l, ok := p.selectedLink(); ok {
+		add(l.Row, l.Col, l.End, l.EndCol, p.theme.SelectedLink)
+	}
+
+	return hs
+}
+
+// span returns the columns of row covered by text that starts at column col
+// of row startRow and ends before column endCol of row endRow, from from up to
+// to. Rows after the first start at their first cell, and rows before the last
+// end after the last cell laid out on them, so that the spaces between words
+// are covered but the margins are not.
+func (p pager) span(row, startRow, col, endRow, endCol int) (from, to int) {
+	if row < startRow || row > endRow {
+		return 0, 0
+	}
+
+	from, to = col, endCol
+	first := -1
+	for x := 0; x < p.doc.Width; x++ {
+		if i := row*p.doc.Width + x; i < len(p.doc.Cells) && p.doc.Cells[i].Ch != 0 {
+			if first < 0 {
+				first = x
+			}
+			if row != endRow {
+				to = x + 1
+			}
+		}
+	}
+	if row != startRow {
+		from = max(first, 0)
+	}
+
+	return from, to
+}
+
+// tooSmall reports whether the terminal is too small for the pager to be
+// drawn in.
+func tooSmall() bool {
+	width, height := viewSize()
+	return width < minViewWidth || height < minViewHeight
+}
 
+// viewSize returns the width and height of the pager's viewport.
+func viewSize() (int, int) {
 	width, height := termbox.Size()
+	height -= statusBarHeight
+	if height < 0 {
+		height = 0
+	}
+
+	return width, height
+}
+
+// draw displays a pager's cell buffer in the terminal.
+func (p pager) draw() {
+	width, height := viewSize()
 	var centerOffset int
+	if width > p.doc.Width {
+		centerOffset = (width - p.doc.Width) / 2
+	}
+	lead := 0
+	if p.scrollY == 0 {
+		lead = len(p.lead)
+	}
+	focused, inFocus := p.doc.Paragraph(p.scrollY)
+	inFocus = inFocus && p.focus
 	for y := 0; y < height; y++ {
-		for x := 0; x < p.doc.width; x++ {
-			index := (y+p.scrollY)*p.doc.width + x
-			if index >= len(p.doc.cells) || index <= 0 {
+		if y < lead {
+			for x, cell := range p.lead[y] {
+				termbox.SetCell(x+p.scrollX+centerOffset, y, cell.Ch, cell.Fg|termbox.AttrDim, p.bg)
+			}
+			continue
+		}
+
+		row := y - lead + p.scrollY
+		text := false
+		highlights := p.highlights(row)
+		for x := 0; x < p.doc.Width; x++ {
+			index := row*p.doc.Width + x
+			if index >= len(p.doc.Cells) || index <= 0 {
 				continue
 			}
-			cell := p.doc.cells[index]
-			if width > p.doc.width {
-				centerOffset = (width - p.doc.width) / 2
+			cell := p.doc.Cells[index]
+			text = text || cell.Ch != 0
+			if p.dim || p.focus && (!inFocus || row < focused.Row || row > focused.End) {
+				cell.Fg |= termbox.AttrDim
+			}
+			if cell.Bg == termbox.ColorDefault {
+				cell.Bg = p.bg
+			}
+			for _, h := range highlights {
+				if x >= h.from && x < h.to {
+					cell.Fg = render.Overlay(cell.Fg, h.attr)
+				}
 			}
 
 			// Calling SetCell with coordinates outside of the terminal viewport
 			// results in a no-op.
 			termbox.SetCell(x+p.scrollX+centerOffset, y, cell.Ch, cell.Fg, cell.Bg)
 		}
+		if text && p.gutter > 0 {
+			n := fmt.Sprintf("%*d", p.gutter-1, p.firstLine+row)
+			printText(p.scrollX+centerOffset, y, p.gutter-1, n, termbox.ColorDefault|termbox.AttrDim, p.bg)
+		}
 	}
+}
 
-	return termbox.Flush()
+// gutterWidth returns the width of a gutter that fits line numbers up to
+// last, with a blank column between them and the text. It fits at least
+// minLineNumberDigits digits, so that most books are numbered in a gutter of
+// the same width throughout.
+func gutterWidth(last int) int {
+	return max(len(strconv.Itoa(last)), minLineNumberDigits) + 1
 }
 
+// minLineNumberDigits is the fewest digits the line number gutter fits.
+const minLineNumberDigits = 4
+
 // scrollDown pans the pager's viewport down, without exceeding the underlying
 // cell buffer document's boundaries.
 func (p *pager) scrollDown() bool {
+	if len(p.lead) > 0 && p.scrollY == 0 {
+		p.lead = p.lead[1:]
+		return true
+	}
 	if p.scrollY < p.maxScrollY() {
 		p.scrollY++
 		return true
@@ -81,7 +263,13 @@ func (p *pager) scrollRight() bool {
 // pageDown pans the pager's viewport down by a full page, without exceeding
 // the underlying cell buffer document's boundaries.
 func (p *pager) pageDown() bool {
-	_, viewHeight := termbox.Size()
+	_, viewHeight := viewSize()
+	if n := len(p.lead); n > 0 && p.scrollY == 0 {
+		// The rows the lead pushed off the first page start the next.
+		p.lead = nil
+		p.scrollY = clamp(viewHeight-n, 0, max(p.maxScrollY(), 0))
+		return true
+	}
 	if p.scrollY < p.maxScrollY() {
 		p.scrollY += viewHeight
 		return true
@@ -93,7 +281,7 @@ func (p *pager) pageDown() bool {
 // pageUp pans the pager's viewport up by a full page, without exceeding the
 // underlying cell buffer document's boundaries.
 func (p *pager) pageUp() bool {
-	_, viewHeight := termbox.Size()
+	_, viewHeight := viewSize()
 	if p.scrollY > viewHeight {
 		p.scrollY -= viewHeight
 		return true
@@ -115,36 +303,224 @@ func (p *pager) toTop() {
 // toBottom set's the pager's horizontal panning distance back to zero and
 // vertical panning distance to the last viewport page.
 func (p *pager) toBottom() {
-	_, viewHeight := termbox.Size()
+	_, viewHeight := viewSize()
+	_, docHeight := p.size()
 	p.scrollX = 0
 	p.scrollY = p.pages() * viewHeight
+
+	// A document that fills its last page exactly has no text after it.
+	if p.scrollY >= docHeight && p.scrollY > 0 {
+		p.scrollY -= viewHeight
+	}
 }
 
 // maxScrollX represents the pager's maximum horizontal scroll distance.
 func (p pager) maxScrollX() int {
 	docWidth, _ := p.size()
-	viewWidth, _ := termbox.Size()
+	viewWidth, _ := viewSize()
 	return docWidth - viewWidth
 }
 
 // maxScrollY represents the pager's maximum vertical scroll distance.
 func (p pager) maxScrollY() int {
 	_, docHeight := p.size()
-	_, viewHeight := termbox.Size()
+	_, viewHeight := viewSize()
 	return docHeight - viewHeight
 }
 
 // size returns the width and height of the pager's underlying cell buffer
 // document.
 func (p pager) size() (int, int) {
-	height := len(p.doc.cells) / p.doc.width
-	return p.doc.width, height
+	return p.doc.Width, p.doc.Rows()
 }
 
 // pages returns the number of times the pager's underlying cell buffer
 // document can be split into viewport sized pages.
 func (p pager) pages() int {
 	_, docHeight := p.size()
-	_, viewHeight := termbox.Size()
+	_, viewHeight := viewSize()
+	if viewHeight <= 0 {
+		return 0
+	}
 	return docHeight / viewHeight
 }
+
+// nextSection moves the pager's viewport to the start of the next section
+// below the current scroll position. It returns false if there is none.
+func (p *pager) nextSection() bool {
+	for _, s := range p.doc.Sections {
+		if s.Row > p.scrollY {
+			p.scrollX = 0
+			p.scrollY = s.Row
+			return true
+		}
+	}
+
+	return false
+}
+
+// prevSection moves the pager's viewport to the start of the section before
+// the one containing the current scroll position. The top of the document is
+// treated as the start of the first section. It returns false if there is no
+// previous section.
+func (p *pager) prevSection() bool {
+	if len(p.doc.Sections) == 0 {
+		return false
+	}
+
+	current, prev := 0, -1
+	for _, s := range p.doc.Sections {
+		if s.Row > p.scrollY {
+			break
+		}
+		if s.Row > current {
+			prev, current = current, s.Row
+		}
+	}
+	if prev < 0 {
+		return false
+	}
+
+	p.scrollX = 0
+	p.scrollY = prev
+	return true
+}
+
+// toLastSection moves the pager's viewport to the start of the last section.
+// It returns false if the document has no sections.
+func (p *pager) toLastSection() bool {
+	if len(p.doc.Sections) == 0 {
+		return false
+	}
+
+	p.scrollX = 0
+	p.scrollY = p.doc.Sections[len(p.doc.Sections)-1].Row
+	return true
+}
+
+// nextHeading moves the pager's viewport to the next heading at or above
+// level below the top of the viewport. It returns false if there is none.
+func (p *pager) nextHeading(level int) bool {
+	for _, h := range p.doc.Headings {
+		if h.Row > p.scrollY && h.Level <= level {
+			p.scrollX = 0
+			p.scrollY = h.Row
+			return true
+		}
+	}
+
+	return false
+}
+
+// prevHeading moves the pager's viewport to the previous heading at or above
+// level above the top of the viewport. It returns false if there is none.
+func (p *pager) prevHeading(level int) bool {
+	for i := len(p.doc.Headings) - 1; i >= 0; i-- {
+		if h := p.doc.Headings[i]; h.Row < p.scrollY && h.Level <= level {
+			p.scrollX = 0
+			p.scrollY = h.Row
+			return true
+		}
+	}
+
+	return false
+}
+
+// alignPage scrolls the pager's viewport up to the start of the viewport sized
+// page it is on, so that the document is read in whole, non-overlapping pages.
+func (p *pager) alignPage() {
+	if _, viewHeight := viewSize(); viewHeight > 0 {
+		p.scrollY -= p.scrollY % viewHeight
+	}
+}
+
+// screen returns the number of the viewport sized page at the top of the
+// pager's viewport, counting from one, and the number of pages in the
+// document.
+func (p pager) screen() (int, int) {
+	_, viewHeight := viewSize()
+	_, docHeight := p.size()
+	if viewHeight <= 0 {
+		return 1, 1
+	}
+
+	return p.scrollY/viewHeight + 1, max((docHeight+viewHeight-1)/viewHeight, 1)
+}
+
+// remaining returns the number of rows holding text in the pager's document
+// from the top of the viewport on, and the number of words on them.
+func (p pager) remaining() (lines, words int) {
+	for y := max(p.scrollY, 0); y < p.doc.Rows(); y++ {
+		inWord, text := false, false
+		for x := 0; x < p.doc.Width; x++ {
+			var ch rune
+			if i := y*p.doc.Width + x; i < len(p.doc.Cells) {
+				ch = p.doc.Cells[i].Ch
+			}
+			letter := ch != 0 && ch != ' '
+			if letter && !inWord {
+				words++
+			}
+			inWord, text = letter, text || letter
+		}
+		if text {
+			lines++
+		}
+	}
+
+	return lines, words
+}
+
+// toRow scrolls the pager's viewport so that row is at the top, or as close
+// as the document's boundaries allow.
+func (p *pager) toRow(row int) {
+	p.scrollY = clamp(row, 0, max(p.maxScrollY(), 0))
+}
+
+// printPage returns the last print page that starts at or above the top of
+// the pager's viewport. It returns false if there is none.
+func (p pager) printPage() (render.Page, bool) {
+	var pg render.Page
+	found := false
+	for _, pp := range p.doc.Pages {
+		if pp.Row > p.scrollY {
+			break
+		}
+		pg, found = pp, true
+	}
+
+	return pg, found
+}
+
+// sectionHeading returns the last heading at or above the top of the pager's
+// viewport that is at level maxLevel or higher. It returns false if there is
+// none.
+func (p pager) sectionHeading(maxLevel int) (render.Heading, bool) {
+	var h render.Heading
+	found := false
+	for _, hd := range p.doc.Headings {
+		if hd.Row > p.scrollY {
+			break
+		}
+		if hd.Level <= maxLevel {
+			h, found = hd, true
+		}
+	}
+
+	return h, found
+}
+
+// titleHeading returns the first of the highest level headings in the pager's
+// document, which is taken to be its title. It returns false if the document
+// has no headings.
+func (p pager) titleHeading() (render.Heading, bool) {
+	var h render.Heading
+	found := false
+	for _, hd := range p.doc.Headings {
+		if !found || hd.Level < h.Level {
+			h, found = hd, true
+		}
+	}
+
+	return h, found
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/taylorskalyo/goreader/render"
+	"github.com/taylorskalyo/goreader/source"
+)
+
+// openBook opens the book read from the file called name. Books without any
+// chapters are rejected, since there would be nothing to display.
+func openBook(name string) (*source.Book, error) {
+	b, err := source.Open(name)
+	if errors.Is(err, zip.ErrFormat) || errors.Is(err, zip.ErrAlgorithm) || errors.Is(err, zip.ErrChecksum) {
+		return nil, fmt.Errorf("cannot unzip contents: %w", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(b.Spine.Itemrefs) == 0 {
+		b.Close()
+		return nil, errors.New("book has no chapters")
+	}
+
+	return b, nil
+}
+
+// setBook makes b, opened from the file at index i of the queue, the current
+// book, closing the previous one. The book is displayed with its saved
+// settings, at its saved reading position. It is not rendered until the next
+// reflow.
+func (a *app) setBook(i int, b *source.Book) {
+	if a.src != nil {
+		a.src.Close()
+	}
+	a.src, a.book = b, b.Rootfile
+	a.name, a.current = a.queue[i], i
+	a.key = bookKey(a.name, a.book)
+	a.text = newBookText(a.book, a.name, a.key)
+
+	bs := a.state.Books[a.key]
+	a.settings = a.config.settings
+	if bs != nil && bs.Settings != nil {
+		a.settings = a.settings.override(bs.Settings)
+	}
+	if a.split != nil {
+		a.settings.Split = *a.split
+	}
+	if a.themeName != nil {
+		a.settings.Theme = *a.themeName
+	}
+	a.setTheme()
+
+	a.details = nil
+	a.history = nil
+	a.visited = nil
+	a.query = ""
+	a.furthest = position{}
+	if bs != nil && bs.Furthest != nil && a.inBook(*bs.Furthest) {
+		a.furthest = *bs.Furthest
+	}
+
+	a.chapter = 0
+	a.pager.doc = render.Document{}
+	a.anchor = anchor{}
+	a.pager.toTop()
+	if bs != nil && bs.Position != nil && a.inBook(*bs.Position) {
+		pos := *bs.Position
+		a.chapter = pos.Chapter
+		a.pager.scrollY = pos.Row
+
+		// The first reflow puts the text that was at the top of the
+		// viewport back there (see reflowAnchor).
+		if pos.Offset > 0 {
+			a.anchor = anchor{chapter: pos.Chapter, row: pos.Row, offset: pos.Offset, set: true}
+		}
+	} else if a.config.StartAtBody {
+		a.toLandmark("bodymatter", "text")
+	}
+}
+
+// switchBook opens the book at index i of the queue where it was last left.
+// If it cannot be opened the current book stays open and the failure is
+// reported in the status bar.
+func (a *app) switchBook(i int) error {
+	name := a.queue[i]
+	if name == source.StdinName {
+		a.message = "Cannot reopen a book read from stdin"
+		return nil
+	}
+
+	b, err := openBook(name)
+	if err != nil {
+		a.message = fmt.Sprintf("Unable to open %s: %s", name, err)
+		return nil
+	}
+
+	a.savePosition()
+	a.endSession()
+	a.setBook(i, b)
+	if err := a.reflow(); err != nil {
+		return err
+	}
+	a.startSession()
+
+	return nil
+}
+
+// closeBook closes the current book. When other books are queued the reader
+// may choose one to read next; it returns true if none was chosen and the
+// program should exit.
+func (a *app) closeBook() (bool, error) {
+	if len(a.queue) < 2 {
+		return true, nil
+	}
+
+	m := &menu{title: "Books", selected: a.current}
+	for _, name := range a.queue {
+		m.entries = append(m.entries, filepath.Base(name))
+	}
+	m.move(0)
+
+	i, err := a.runMenu(m)
+	if err != nil || i < 0 {
+		return true, err
+	}
+	if i == a.current {
+		return false, nil
+	}
+
+	return false, a.switchBook(i)
+}
+
+// savePosition records where the current book was left in the state file.
+// Failures are ignored; losing the position must never interrupt reading.
+func (a *app) savePosition() {
+	if a.name == source.StdinName {
+		return
+	}
+
+	bs := a.state.book(a.key)
+	pos := a.here()
+	bs.Position = &pos
+	furthest := a.furthest
+	bs.Furthest = &furthest
+	a.state.save()
+}
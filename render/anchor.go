@@ -0,0 +1,48 @@
+package render
+
+import "sort"
+
+// anchor ties a row of a document to the source offset of the first text
+// laid out on it.
+type anchor struct {
+	row    int
+	offset int
+}
+
+// addAnchor records that row holds text from the document's current source
+// offset, unless text has already been laid out on it.
+func (c *Document) addAnchor(row int) {
+	if n := len(c.anchors); n > 0 && c.anchors[n-1].row >= row {
+		return
+	}
+	c.anchors = append(c.anchors, anchor{row: row, offset: c.offset})
+}
+
+// Offset returns the position in the document's source of the first text at
+// or below row. Since it does not depend on how the text is laid out, it can
+// be used to find the same text in the document after it is parsed again with
+// different options (see Row). It returns false if there is no text below
+// row.
+func (c Document) Offset(row int) (int, bool) {
+	i := sort.Search(len(c.anchors), func(i int) bool {
+		return c.anchors[i].row >= row
+	})
+	if i == len(c.anchors) {
+		return 0, false
+	}
+
+	return c.anchors[i].offset, true
+}
+
+// Row returns the row holding the text at offset in the document's source, as
+// returned by Offset.
+func (c Document) Row(offset int) int {
+	i := sort.Search(len(c.anchors), func(i int) bool {
+		return c.anchors[i].offset > offset
+	})
+	if i == 0 {
+		return 0
+	}
+
+	return c.anchors[i-1].row
+}
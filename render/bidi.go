@@ -0,0 +1,33 @@
+package render
+
+import "strings"
+
+// isBidiControl reports whether r is one of the invisible characters that
+// control the direction of bidirectional text: the directional marks,
+// embeddings, overrides and isolates. Text is laid out left to right, so they
+// have no effect on it, but terminals may show them as boxes or count them as
+// taking up a column.
+func isBidiControl(r rune) bool {
+	switch r {
+	case '\u200e', '\u200f', '\u061c':
+		return true
+	}
+
+	return '\u202a' <= r && r <= '\u202e' || '\u2066' <= r && r <= '\u2069'
+}
+
+// stripBidi returns s without its bidirectional control characters, so that
+// they are neither displayed nor counted in the width of the text they are in.
+// The text they isolate or embed is kept in place.
+func stripBidi(s string) string {
+	if !strings.ContainsFunc(s, isBidiControl) {
+		return s
+	}
+
+	return strings.Map(func(r rune) rune {
+		if isBidiControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
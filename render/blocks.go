@@ -0,0 +1,86 @@
+package render
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/nfnt/resize"
+	termbox "github.com/nsf/termbox-go"
+)
+
+// upperHalfBlock fills the top half of a cell with its foreground color,
+// leaving the bottom half in its background color.
+const upperHalfBlock = '▀'
+
+// renderBlocks renders an image in color as rows of upper half blocks, so that
+// each cell shows two pixels, one above the other: the top in the cell's
+// foreground color and the bottom in its background color. A cell is taken
+// to be twice as high as it is wide, as for ASCII art, so the image is
+// rendered to as many rows as RenderImage would. Colors are the nearest in the
+// 256-color palette (see paletteColor). Images with no area, or that would be
+// rendered with no columns, produce no rows.
+func renderBlocks(img image.Image, opts ImageOptions) [][]termbox.Cell {
+	w := opts.Width
+	bounds := img.Bounds()
+	if bounds.Dx() <= 0 || bounds.Dy() <= 0 || w <= 0 {
+		return nil
+	}
+
+	h := max((bounds.Dy()*w)/(bounds.Dx()*2), 1)
+	img = resize.Resize(uint(w), uint(h*2), img, resize.Lanczos3)
+	bounds = img.Bounds()
+	at := func(x, y int) termbox.Attribute {
+		c := color.RGBAModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.RGBA)
+		return paletteColor(opts.adjust(c.R), opts.adjust(c.G), opts.adjust(c.B))
+	}
+
+	rows := make([][]termbox.Cell, h)
+	for y := range rows {
+		rows[y] = make([]termbox.Cell, w)
+		for x := range rows[y] {
+			rows[y][x] = termbox.Cell{Ch: upperHalfBlock, Fg: at(x, 2*y), Bg: at(x, 2*y+1)}
+		}
+	}
+
+	return rows
+}
+
+// cubeLevels are the intensities of each channel in the 6×6×6 color cube of
+// the 256-color palette, which starts at index 16. The 24 shades of gray that
+// follow it, from index 232, run from 8 to 238 in steps of 10.
+var cubeLevels = [6]int{0, 95, 135, 175, 215, 255}
+
+// paletteColor returns the attribute of the color in the 256-color palette
+// nearest to the given one, among those of the color cube and the shades of
+// gray. The first 16 colors are left out, since terminals differ in how they
+// show them. Attributes are one more than the index of their color, as
+// termbox expects in its 256-color output mode.
+func paletteColor(r, g, b uint8) termbox.Attribute {
+	level := func(v uint8) int {
+		switch {
+		case v < 48:
+			return 0
+		case v < 115:
+			return 1
+		default:
+			return (int(v) - 35) / 40
+		}
+	}
+	dist := func(r2, g2, b2 int) int {
+		dr, dg, db := int(r)-r2, int(g)-g2, int(b)-b2
+		return dr*dr + dg*dg + db*db
+	}
+
+	ri, gi, bi := level(r), level(g), level(b)
+	cube := 16 + 36*ri + 6*gi + bi
+	cubeDist := dist(cubeLevels[ri], cubeLevels[gi], cubeLevels[bi])
+
+	avg := (int(r) + int(g) + int(b)) / 3
+	gray := min(max((avg-3)/10, 0), 23)
+	v := 8 + 10*gray
+	if dist(v, v, v) < cubeDist {
+		return termbox.Attribute(232 + gray + 1)
+	}
+
+	return termbox.Attribute(cube + 1)
+}
@@ -0,0 +1,145 @@
+package render
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// styleRule is a CSS rule with a simple selector: an element name, a class,
+// or both (e.g. "h1.title"). Rules with other selectors are ignored.
+type styleRule struct {
+	tag   string
+	class string
+	decls map[string]string
+}
+
+// cssComment matches comments within a stylesheet.
+var cssComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// parseStylesheet returns the rules of a stylesheet that have simple
+// selectors.
+func parseStylesheet(css string) []styleRule {
+	var rules []styleRule
+	css = cssComment.ReplaceAllString(css, "")
+	for _, block := range strings.Split(css, "}") {
+		selectors, body, ok := strings.Cut(block, "{")
+		if !ok {
+			continue
+		}
+		decls := parseDeclarations(body)
+		for _, sel := range strings.Split(selectors, ",") {
+			sel = strings.TrimSpace(sel)
+			if sel == "" || strings.ContainsAny(sel, " >+~:#[*@") {
+				continue
+			}
+			tag, class, _ := strings.Cut(sel, ".")
+			if strings.Contains(class, ".") {
+				continue
+			}
+			rules = append(rules, styleRule{strings.ToLower(tag), class, decls})
+		}
+	}
+
+	return rules
+}
+
+// addStylesheet reads the rules of the linked stylesheet at href. Stylesheets
+// that cannot be read are ignored.
+func (p *parser) addStylesheet(href string) {
+	item, ok := p.item(href)
+	if !ok {
+		return
+	}
+	r, err := item.Open()
+	if err != nil {
+		return
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	p.rules = append(p.rules, parseStylesheet(string(b))...)
+}
+
+// parseDeclarations parses CSS declarations, such as those in an inline style
+// attribute, into a map of lower case property names to values.
+func parseDeclarations(s string) map[string]string {
+	decls := make(map[string]string)
+	for _, decl := range strings.Split(s, ";") {
+		prop, val, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		val = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(val), "!important"))
+		decls[strings.ToLower(strings.TrimSpace(prop))] = val
+	}
+
+	return decls
+}
+
+// matches reports whether a rule's selector matches an element.
+func (r styleRule) matches(token html.Token) bool {
+	if r.tag != "" && r.tag != token.Data {
+		return false
+	}
+
+	return r.class == "" || hasWord(tokenAttr(token, "class"), r.class)
+}
+
+// cssValue returns the value of a CSS property for an element, as set by the
+// parser's stylesheets and the element's inline style. Later rules take
+// precedence over earlier ones, and inline styles over both.
+func (p *parser) cssValue(token html.Token, prop string) (string, bool) {
+	val, ok := parseDeclarations(tokenAttr(token, "style"))[prop]
+	if ok {
+		return strings.ToLower(val), true
+	}
+	for i := len(p.rules) - 1; i >= 0; i-- {
+		if val, ok := p.rules[i].decls[prop]; ok && p.rules[i].matches(token) {
+			return strings.ToLower(val), true
+		}
+	}
+
+	return "", false
+}
+
+// textTransform returns the text-transform of an element, inheriting that
+// of its parent.
+func (p *parser) textTransform(token html.Token) string {
+	parent := "none"
+	if n := len(p.transforms); n > 0 {
+		parent = p.transforms[n-1]
+	}
+
+	switch val, _ := p.cssValue(token, "text-transform"); val {
+	case "none", "uppercase", "lowercase", "capitalize":
+		return val
+	}
+
+	return parent
+}
+
+// transform applies the current text-transform to text.
+func (p *parser) transform(text string) string {
+	if len(p.transforms) == 0 {
+		return text
+	}
+
+	switch p.transforms[len(p.transforms)-1] {
+	case "uppercase":
+		return cases.Upper(language.Und).String(text)
+	case "lowercase":
+		return cases.Lower(language.Und).String(text)
+	case "capitalize":
+		return cases.Title(language.Und, cases.NoLower).String(text)
+	}
+
+	return text
+}
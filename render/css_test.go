@@ -0,0 +1,64 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStylesheet(t *testing.T) {
+	css := `/* headings */
+h1, h2.part { text-transform: uppercase; color: red }
+.label { text-transform: capitalize !important; }
+div p { text-transform: lowercase; }
+a:hover { color: blue; }`
+
+	rules := parseStylesheet(css)
+	exp := []styleRule{
+		{tag: "h1", decls: map[string]string{"text-transform": "uppercase", "color": "red"}},
+		{tag: "h2", class: "part", decls: map[string]string{"text-transform": "uppercase", "color": "red"}},
+		{class: "label", decls: map[string]string{"text-transform": "capitalize"}},
+	}
+	if len(rules) != len(exp) {
+		t.Fatalf(expFormat, exp, rules)
+	}
+	for i, r := range rules {
+		if r.tag != exp[i].tag || r.class != exp[i].class {
+			t.Errorf(expFormat, exp[i], r)
+		}
+		for k, v := range exp[i].decls {
+			if r.decls[k] != v {
+				t.Errorf(expFormat, v, r.decls[k])
+			}
+		}
+	}
+}
+
+func TestTextTransform(t *testing.T) {
+	style := `<style>
+h2 { text-transform: uppercase }
+.name { text-transform: capitalize }
+</style>`
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Stylesheet", style + `<h2>straße</h2>`, "STRASSE"},
+		{"Inherited", style + `<h2><span>one</span> two</h2>`, "ONE TWO"},
+		{"Class", style + `<div class="x name">émile zola</div>`, "Émile Zola"},
+		{"Inline", `<div style="text-transform: lowercase">ÀB <b>CD</b></div>`, "àb cd"},
+		{"None", style + `<h2>one <span style="text-transform: none">Two</span></h2>`, "ONE Two"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := strings.TrimSpace(doc.String()); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
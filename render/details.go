@@ -0,0 +1,115 @@
+package render
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Detail is a collapsible <details> element within a cell buffer document.
+type Detail struct {
+	// Row is the row its summary starts on.
+	Row int
+
+	// Open reports whether its content is shown.
+	Open bool
+}
+
+// Indicators shown before the summary of collapsed and expanded details.
+const (
+	collapsedIndicator = "▸ "
+	expandedIndicator  = "▾ "
+)
+
+// startDetails starts a <details> element, whose content is shown if it is
+// expanded by the options, by its open attribute, or by default.
+func (p *parser) startDetails(token html.Token) {
+	i := len(p.doc.Details)
+	open, ok := p.details[i]
+	if !ok {
+		open = p.expandDetails || hasAttr(token, "open")
+	}
+
+	p.doc.startLine()
+	p.doc.Details = append(p.doc.Details, Detail{Row: p.doc.row, Open: open})
+	if !open && token.Type == html.StartTagToken && len(p.overflow) == 0 {
+		p.collapsed = len(p.tagStack)
+	}
+}
+
+// startHidden hides an element with the hidden attribute, along with its
+// content. Such elements (e.g. a navigation document's landmarks) are not
+// meant to be shown in the flow of the text.
+func (p *parser) startHidden(token html.Token) {
+	if p.hiddenDepth == 0 && len(p.overflow) == 0 && hasAttr(token, "hidden") {
+		p.hiddenDepth = len(p.tagStack)
+	}
+}
+
+// endHidden is called after an element closes. It shows the content after
+// hidden elements and collapsed details once they close, and ends the line
+// after a summary or <details> element that is shown.
+func (p *parser) endHidden(tag atom.Atom) {
+	if p.collapsed > len(p.tagStack) {
+		p.collapsed = 0
+	}
+	if p.hiddenDepth > len(p.tagStack) {
+		p.hiddenDepth = 0
+	}
+	if p.hiddenDepth > 0 {
+		return
+	}
+
+	switch tag {
+	case atom.Summary:
+		if p.collapsed == 0 || len(p.tagStack) <= p.collapsed {
+			p.doc.startLine()
+		}
+	case atom.Details:
+		if p.collapsed == 0 || len(p.tagStack) < p.collapsed {
+			p.doc.startLine()
+		}
+	}
+}
+
+// skipDetails counts a <details> element within collapsed content, so that
+// the elements after it keep their index whether or not it is shown.
+func (p *parser) skipDetails() {
+	p.doc.Details = append(p.doc.Details, Detail{Row: -1})
+}
+
+// startSummary starts the summary of the innermost <details> element, marking
+// whether it is expanded.
+func (p *parser) startSummary() {
+	p.doc.startLine()
+	indicator := expandedIndicator
+	if p.collapsed > 0 {
+		indicator = collapsedIndicator
+	}
+	p.text.WriteString(indicator)
+}
+
+// hidden reports whether the current element is hidden, or within collapsed
+// details other than in their summary.
+func (p *parser) hidden() bool {
+	if p.hiddenDepth > 0 && len(p.tagStack) >= p.hiddenDepth {
+		return true
+	}
+
+	d := p.collapsed
+	if d == 0 || len(p.tagStack) < d {
+		return false
+	}
+
+	return len(p.tagStack) == d || p.tagStack[d] != atom.Summary
+}
+
+// hasAttr reports whether a token has the named attribute.
+func hasAttr(token html.Token, key string) bool {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,131 @@
+package render
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// divisionTypes are the structural values of the EPUB3 epub:type attribute,
+// which mark the divisions of a book such as its parts and chapters.
+var divisionTypes = map[string]bool{
+	"volume":       true,
+	"part":         true,
+	"chapter":      true,
+	"division":     true,
+	"prologue":     true,
+	"epilogue":     true,
+	"preface":      true,
+	"foreword":     true,
+	"introduction": true,
+	"preamble":     true,
+	"conclusion":   true,
+	"afterword":    true,
+	"appendix":     true,
+	"glossary":     true,
+	"bibliography": true,
+	"index":        true,
+	"colophon":     true,
+	"footnotes":    true,
+	"endnotes":     true,
+	"rearnotes":    true,
+}
+
+// ruledDivisions are the division types that are set apart from the text
+// before them by a rule, rather than only a blank row, when section breaks
+// are enabled.
+var ruledDivisions = map[string]bool{
+	"volume": true,
+	"part":   true,
+}
+
+// noteDivisions are the division types that hold notes rather than the body
+// of the text.
+var noteDivisions = map[string]bool{
+	"footnotes": true,
+	"endnotes":  true,
+	"rearnotes": true,
+}
+
+// Section is the start of a section of a document: either a heading at or
+// above the split level, or an element with a structural epub:type.
+type Section struct {
+	Row int
+
+	// Type is the structural epub:type of the innermost division the
+	// section starts in (e.g. "chapter" or "footnotes"), or empty if it
+	// has none, as in EPUB2 books.
+	Type string
+}
+
+// Notes reports whether the section holds notes, such as footnotes, rather
+// than the body of the text.
+func (s Section) Notes() bool {
+	return noteDivisions[s.Type]
+}
+
+// division is an element with a structural epub:type that is being parsed.
+type division struct {
+	typ   string
+	depth int
+}
+
+// divisionType returns the first structural type in a token's epub:type
+// attribute, or an empty string if it has none.
+func divisionType(token html.Token) string {
+	for _, w := range strings.Fields(tokenAttr(token, "epub:type")) {
+		if divisionTypes[w] {
+			return w
+		}
+	}
+
+	return ""
+}
+
+// startDivision starts an element with a structural epub:type. The type of
+// <body> is that of the whole document. Other elements are set apart from the
+// text before them when section breaks are enabled, and start a new section
+// when chapters are split.
+func (p *parser) startDivision(token html.Token) {
+	typ := divisionType(token)
+	if typ == "" || token.Type != html.StartTagToken {
+		return
+	}
+	p.divisions = append(p.divisions, division{typ: typ, depth: len(p.tagStack)})
+	if token.DataAtom == atom.Body {
+		p.doc.Type = typ
+		return
+	}
+
+	switch {
+	case !p.sectionBreaks || len(p.doc.Cells) == 0:
+		p.doc.startLine()
+	case ruledDivisions[typ]:
+		p.doc.breakPage()
+		p.doc.appendText(strings.Repeat("-", p.doc.textWidth()))
+		p.doc.breakPage()
+	default:
+		p.doc.breakPage()
+	}
+	if p.splitLevel > 0 {
+		p.doc.addSection(p.doc.row, typ)
+	}
+}
+
+// endDivisions ends the divisions whose elements have been closed.
+func (p *parser) endDivisions() {
+	for n := len(p.divisions); n > 0 && len(p.tagStack) < p.divisions[n-1].depth; n-- {
+		p.divisions = p.divisions[:n-1]
+	}
+}
+
+// division returns the type of the innermost division being parsed, or an
+// empty string if there is none.
+func (p *parser) division() string {
+	if n := len(p.divisions); n > 0 {
+		return p.divisions[n-1].typ
+	}
+
+	return ""
+}
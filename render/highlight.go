@@ -0,0 +1,124 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	termbox "github.com/nsf/termbox-go"
+	"golang.org/x/net/html"
+)
+
+// tabWidth is the number of columns a tab advances to in preformatted text.
+const tabWidth = 4
+
+// codeBlock buffers the contents of a code element so it can be highlighted
+// as a whole once the element ends.
+type codeBlock struct {
+	lexer chroma.Lexer
+
+	// depth is the size of the tag stack when the code element started.
+	depth int
+	text  strings.Builder
+}
+
+// codeLanguage returns the language declared by an element's class attribute
+// using the "language-*" (or "lang-*") convention, if any.
+func codeLanguage(token html.Token) string {
+	for _, a := range token.Attr {
+		if a.Key != "class" {
+			continue
+		}
+		for _, class := range strings.Fields(a.Val) {
+			for _, prefix := range []string{"language-", "lang-"} {
+				if strings.HasPrefix(class, prefix) {
+					return strings.TrimPrefix(class, prefix)
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// newCodeBlock returns a codeBlock for the language declared on token, or nil
+// if no known language is declared.
+func newCodeBlock(token html.Token, depth int) *codeBlock {
+	lang := codeLanguage(token)
+	if lang == "" {
+		return nil
+	}
+
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		return nil
+	}
+
+	return &codeBlock{lexer: chroma.Coalesce(lexer), depth: depth}
+}
+
+// tokenColor maps a lexer token type to a foreground attribute.
+func tokenColor(t chroma.TokenType) termbox.Attribute {
+	switch {
+	case t.InCategory(chroma.Keyword):
+		return termbox.ColorBlue | termbox.AttrBold
+	case t.InCategory(chroma.Comment):
+		return termbox.ColorCyan
+	case t.InSubCategory(chroma.LiteralString):
+		return termbox.ColorGreen
+	case t.InSubCategory(chroma.LiteralNumber):
+		return termbox.ColorMagenta
+	case t == chroma.NameFunction, t == chroma.NameBuiltin:
+		return termbox.ColorYellow
+	}
+
+	return termbox.ColorDefault
+}
+
+// appendCode highlights the buffered code and appends it to the cell buffer
+// document verbatim, starting on a new line. Lines that do not fit are hard
+// wrapped at the right margin.
+func (c *Document) appendCode(b *codeBlock) {
+	// A newline immediately following the start tag is not part of the
+	// content.
+	text := strings.TrimPrefix(b.text.String(), "\n")
+	text = strings.TrimRight(text, "\n")
+
+	if c.col > c.lmargin {
+		c.newline()
+	}
+	c.col = c.lmargin
+	defer c.newline()
+
+	it, err := b.lexer.Tokenise(nil, text)
+	if err != nil {
+		c.appendRunes(text, c.fg)
+		return
+	}
+	for tok := it(); tok != chroma.EOF; tok = it() {
+		c.appendRunes(tok.Value, c.fg|tokenColor(tok.Type))
+	}
+}
+
+// appendRunes writes str to the cell buffer document verbatim, honoring
+// newlines and tabs.
+func (c *Document) appendRunes(str string, fg termbox.Attribute) {
+	for _, r := range str {
+		switch r {
+		case '\n':
+			c.row++
+			c.col = c.lmargin
+			continue
+		case '\t':
+			n := tabWidth - (c.col-c.lmargin)%tabWidth
+			c.appendRunes(strings.Repeat(" ", n), fg)
+			continue
+		}
+
+		if c.col+runeWidth(r) > c.Width-c.rmargin {
+			c.row++
+			c.col = c.lmargin
+		}
+		c.putRune(r, fg)
+	}
+}
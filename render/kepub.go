@@ -0,0 +1,32 @@
+package render
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// koboSpan reports whether a token is the start or end tag of one of the
+// spans that Kobo wraps each sentence in, in KEPUB books. They carry no
+// formatting and are dropped, so that they do not separate the text on either
+// side of them.
+func (p *parser) koboSpan(tokenType html.TokenType, token html.Token) bool {
+	if token.DataAtom != atom.Span {
+		return false
+	}
+
+	switch tokenType {
+	case html.StartTagToken:
+		kobo := hasWord(tokenAttr(token, "class"), "koboSpan")
+		p.spans = append(p.spans, kobo)
+		return kobo
+	case html.EndTagToken:
+		if len(p.spans) == 0 {
+			return false
+		}
+		kobo := p.spans[len(p.spans)-1]
+		p.spans = p.spans[:len(p.spans)-1]
+		return kobo
+	}
+
+	return false
+}
@@ -0,0 +1,164 @@
+package render
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Layout is a named preset controlling how paragraphs are set out.
+type Layout struct {
+	Name string
+
+	// Indent is the number of columns the first line of each paragraph is
+	// indented by.
+	Indent int
+
+	// ParagraphSpacing is the number of blank rows between paragraphs.
+	ParagraphSpacing int
+}
+
+// Layouts lists the available layout presets. The first is the default.
+var Layouts = []Layout{
+	// novel indents the first line of each paragraph, without a gap between
+	// paragraphs.
+	{Name: "novel", Indent: 2},
+
+	// article separates paragraphs with a blank line instead of indenting
+	// them.
+	{Name: "article", ParagraphSpacing: 1},
+
+	// compact neither indents nor separates paragraphs beyond starting them
+	// on a new line.
+	{Name: "compact"},
+}
+
+// LookupLayout returns the layout preset with the given name. An empty name
+// selects the default layout.
+func LookupLayout(name string) (Layout, bool) {
+	if name == "" {
+		return Layouts[0], true
+	}
+	for _, l := range Layouts {
+		if l.Name == name {
+			return l, true
+		}
+	}
+
+	return Layouts[0], false
+}
+
+// lineElements are the block elements that start on a new line, without the
+// spacing of a paragraph.
+var lineElements = map[atom.Atom]bool{
+	atom.Li:         true,
+	atom.Dt:         true,
+	atom.Dd:         true,
+	atom.Blockquote: true,
+	atom.Tr:         true,
+}
+
+// containerElements are the elements whose first paragraph continues from
+// where the element starts, rather than being set out as a paragraph of its
+// own. This keeps e.g. the paragraph in <li><p>...</p></li> on the item's
+// line.
+var containerElements = map[atom.Atom]bool{
+	atom.Li: true,
+	atom.Dt: true,
+	atom.Dd: true,
+	atom.Td: true,
+	atom.Th: true,
+}
+
+// indentElements are the block elements whose content is indented, by the
+// given number of columns for each of them that encloses it. Lists leave room
+// for the markers of their items: a bullet, or a number of one digit, which
+// longer numbers extend to the left of.
+var indentElements = map[atom.Atom]int{
+	atom.Blockquote: blockIndent,
+	atom.Ul:         len([]rune(bullet)),
+	atom.Ol:         len("1. "),
+}
+
+// blockIndent is the number of columns a block quotation indents its content
+// by.
+const blockIndent = 4
+
+// indentFor returns the indent of content within the elements in tags, the
+// sum of the indents of those that are indented blocks.
+func indentFor(tags []atom.Atom) int {
+	n := 0
+	for _, tag := range tags {
+		n += indentElements[tag]
+	}
+
+	return n
+}
+
+// indent sets the left margin for the elements open in the tag stack,
+// starting a new line if it changes. Deeply nested content is indented no
+// further than leaves MinTextWidth columns for text.
+func (p *parser) indent() {
+	c := &p.doc
+	limit := max(c.Width-c.rmargin-c.margin-MinTextWidth, 0)
+	margin := c.margin + min(indentFor(p.tagStack)+p.listIndent(), limit)
+	if margin == c.lmargin {
+		return
+	}
+	c.startLine()
+	c.balance()
+	c.lmargin, c.col = margin, margin
+}
+
+// startContainer positions the cursor for the content of a block element,
+// after the marker of a list item. Its text is never joined to the text
+// before it, even when they share a line (e.g. in adjacent table cells).
+func (p *parser) startContainer(token html.Token) {
+	tag := token.DataAtom
+	p.doc.joined = false
+	if lineElements[tag] {
+		p.doc.startLine()
+	}
+	if tag == atom.Li {
+		p.startItem(token)
+	}
+	if containerElements[tag] {
+		p.container = true
+		p.containerRow, p.containerCol = p.doc.row, p.doc.col
+	}
+}
+
+// atContainerStart reports whether nothing has been laid out since the last
+// container element started.
+func (p *parser) atContainerStart() bool {
+	return p.container && p.doc.row == p.containerRow && p.doc.col == p.containerCol
+}
+
+// startLine moves the cursor to the start of a line, starting a new one if
+// the current line has text on it.
+func (c *Document) startLine() {
+	c.breaks = 0
+	if c.col > c.lmargin {
+		c.newline()
+	}
+	c.col = c.lmargin
+	c.addParagraph(c.row)
+}
+
+// lineBreaks lays out the line breaks since text was last appended. The first
+// ends the current line and each of the others leaves a blank line, up to
+// maxBreaks of them. Breaks at the start of the document or just before a
+// new block (see startLine) are dropped, since the block sets its own
+// spacing.
+func (c *Document) lineBreaks() {
+	n := c.breaks
+	c.breaks = 0
+	if n == 0 || len(c.Cells) == 0 {
+		return
+	}
+
+	if c.col > c.lmargin {
+		c.newline()
+	}
+	c.col = c.lmargin
+	c.row += min(n-1, c.maxBreaks) * (1 + c.lineSpacing)
+}
@@ -0,0 +1,158 @@
+package render
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Link is a hyperlink within a cell buffer document. The link's text starts
+// at column Col of row Row and ends before column EndCol of row End. Href is
+// the link's target as given in the source, e.g. "chapter2.xhtml#notes".
+type Link struct {
+	Row, Col    int
+	End, EndCol int
+	Text        string
+	Href        string
+
+	// Item is the href, in the manifest, of the document in the book that
+	// the link points into, and Fragment the id it points to within it.
+	// Links within their own document (e.g. "#notes") have no Item, and
+	// those out of the book neither.
+	Item     string
+	Fragment string
+}
+
+// Local reports whether the link points within its own document.
+func (l Link) Local() bool {
+	return strings.HasPrefix(l.Href, "#")
+}
+
+// linkElement is a hyperlink that is being parsed.
+type linkElement struct {
+	href string
+
+	// start is the index of the cell the cursor was at when the element
+	// started.
+	start int
+
+	// depth is the size of the tag stack when the element started.
+	depth int
+
+	// text holds the element's text.
+	text strings.Builder
+}
+
+// startLink starts a hyperlink, unless it is within another.
+func (p *parser) startLink(token html.Token) {
+	if p.link != nil || token.DataAtom != atom.A || token.Type != html.StartTagToken {
+		return
+	}
+	if href := strings.TrimSpace(tokenAttr(token, "href")); href != "" {
+		p.link = &linkElement{
+			href:  href,
+			start: p.doc.row*p.doc.Width + p.doc.col,
+			depth: len(p.tagStack),
+		}
+	}
+}
+
+// endLink ends the current hyperlink. Links without text are left out. The
+// link covers the cells from the first to the last that its text was laid out
+// in.
+func (p *parser) endLink() {
+	l := p.link
+	p.link = nil
+	text := strings.Join(strings.Fields(l.text.String()), " ")
+	if text == "" {
+		return
+	}
+
+	c := &p.doc
+	written := func(i int) bool {
+		return i < len(c.Cells) && c.Cells[i].Ch != 0 && c.Cells[i].Ch != ' '
+	}
+	start, end := l.start, c.row*c.Width+c.col
+	for start < end && !written(start) {
+		start++
+	}
+	for end > start && !written(end-1) {
+		end--
+	}
+	if start == end {
+		return
+	}
+	link := Link{
+		Row:    start / c.Width,
+		Col:    start % c.Width,
+		End:    (end - 1) / c.Width,
+		EndCol: (end-1)%c.Width + 1,
+		Text:   text,
+		Href:   l.href,
+	}
+	link.Item, link.Fragment = p.linkTarget(l.href)
+	c.Links = append(c.Links, link)
+}
+
+// linkTarget returns the manifest href of the document in the book that href
+// points into, if any, and the fragment it points to. Hrefs are resolved as
+// images are (see item), and percent-encoding is undone.
+func (p *parser) linkTarget(href string) (item, fragment string) {
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", ""
+	}
+	if u.Path != "" {
+		if i, ok := p.item(u.Path); ok {
+			item = i.HREF
+		}
+	}
+
+	return item, u.Fragment
+}
+
+// addID records the source offset of an element with an id, or of an anchor
+// with a name, as older books mark the targets of links, so that the row it
+// starts on can be found once the document is laid out (see resolveIDs). Only
+// the first element with a given id is recorded.
+func (p *parser) addID(token html.Token) {
+	id := tokenAttr(token, "id")
+	if id == "" && token.DataAtom == atom.A {
+		id = tokenAttr(token, "name")
+	}
+	if id == "" {
+		return
+	}
+	if _, ok := p.ids[id]; ok {
+		return
+	}
+	if p.ids == nil {
+		p.ids = map[string]int{}
+	}
+	p.ids[id] = p.doc.offset
+}
+
+// resolveIDs sets the row of each element with an id to that of the first
+// text laid out after its start tag, since a block element's start tag comes
+// before the line it starts. Elements with no text after them are on the last
+// row.
+func (p *parser) resolveIDs() {
+	if len(p.ids) == 0 {
+		return
+	}
+	p.doc.IDs = make(map[string]int, len(p.ids))
+	anchors := p.doc.anchors
+	for id, offset := range p.ids {
+		i := sort.Search(len(anchors), func(i int) bool {
+			return anchors[i].offset >= offset
+		})
+		if i < len(anchors) {
+			p.doc.IDs[id] = anchors[i].row
+		} else {
+			p.doc.IDs[id] = max(p.doc.Rows()-1, 0)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package render
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Problem is an issue found in a document's source while it was parsed, of
+// the kind its author would want to fix (e.g. an image missing from the
+// manifest). Problems are only looked for when Options.Lint is set.
+type Problem struct {
+	// Line is the line of the source the problem was found on, counting
+	// from one, or zero for problems with the document as a whole.
+	Line    int
+	Message string
+}
+
+// String returns the problem's message, preceded by its line.
+func (pr Problem) String() string {
+	if pr.Line == 0 {
+		return pr.Message
+	}
+
+	return fmt.Sprintf("line %d: %s", pr.Line, pr.Message)
+}
+
+// optionalEndTags are the HTML elements whose end tag may be left out, and so
+// are not reported when they are closed implicitly.
+var optionalEndTags = map[atom.Atom]bool{
+	atom.Html:     true,
+	atom.Head:     true,
+	atom.Body:     true,
+	atom.P:        true,
+	atom.Li:       true,
+	atom.Dt:       true,
+	atom.Dd:       true,
+	atom.Rb:       true,
+	atom.Rt:       true,
+	atom.Rtc:      true,
+	atom.Rp:       true,
+	atom.Optgroup: true,
+	atom.Option:   true,
+	atom.Colgroup: true,
+	atom.Caption:  true,
+	atom.Thead:    true,
+	atom.Tbody:    true,
+	atom.Tfoot:    true,
+	atom.Tr:       true,
+	atom.Td:       true,
+	atom.Th:       true,
+}
+
+// xmlEncoding matches the encoding given by an XML declaration.
+var xmlEncoding = regexp.MustCompile(`encoding\s*=\s*["']([^"']+)["']`)
+
+// problem records a problem found on the current line of the source.
+func (p *parser) problem(format string, args ...any) {
+	p.doc.Problems = append(p.doc.Problems, Problem{
+		Line:    p.line,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// lint looks for problems with a token, before it is parsed.
+func (p *parser) lint(tokenType html.TokenType, token html.Token) {
+	raw := p.tokenizer.Raw()
+	p.line = p.lines + 1
+	p.lines += bytes.Count(raw, []byte("\n"))
+
+	switch tokenType {
+	case html.ErrorToken:
+		p.lintUnclosed(0, "at the end of the document")
+	case html.CommentToken:
+		if m := xmlEncoding.FindStringSubmatch(token.Data); strings.HasPrefix(token.Data, "?xml") && m != nil {
+			p.lintEncoding(m[1])
+		}
+	case html.TextToken:
+		if !p.invalidText && (!utf8.Valid(raw) || strings.ContainsRune(token.Data, utf8.RuneError)) {
+			p.problem("text is not valid UTF-8")
+			p.invalidText = true
+		}
+	case html.StartTagToken, html.SelfClosingTagToken:
+		switch token.DataAtom {
+		case atom.Img:
+			p.lintImage(tokenAttr(token, "src"))
+		case atom.Meta:
+			if charset := tokenAttr(token, "charset"); charset != "" {
+				p.lintEncoding(charset)
+			} else if _, charset, ok := strings.Cut(strings.ToLower(tokenAttr(token, "content")), "charset="); ok {
+				p.lintEncoding(charset)
+			}
+		}
+	case html.EndTagToken:
+		p.lintEndTag(token)
+	}
+}
+
+// lintEncoding reports documents declared to be in an encoding other than
+// UTF-8, which is the only one they are read in.
+func (p *parser) lintEncoding(name string) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return
+	}
+	p.problem("unsupported encoding %q", name)
+}
+
+// lintImage reports images that are missing from the manifest, or cannot be
+// decoded.
+func (p *parser) lintImage(src string) {
+	if src == "" {
+		p.problem("image has no source")
+		return
+	}
+	item, ok := p.item(src)
+	if !ok {
+		p.problem("image %s not found in the manifest", src)
+		return
+	}
+
+	r, err := item.Open()
+	if err != nil {
+		p.problem("image %s cannot be opened: %s", src, err)
+		return
+	}
+	defer r.Close()
+	if isSVG(item) {
+		if _, err := decodeSVG(r); err != nil {
+			p.problem("image %s cannot be decoded: %s", src, err)
+		}
+	} else if _, _, err := image.DecodeConfig(r); errors.Is(err, image.ErrFormat) {
+		p.problem("image %s has an unsupported format (%s)", src, item.MediaType)
+	} else if err != nil {
+		p.problem("image %s cannot be decoded: %s", src, err)
+	}
+}
+
+// lintEndTag reports end tags that close elements left open within the
+// element they end, and end tags that do not end an open element.
+func (p *parser) lintEndTag(token html.Token) {
+	if token.DataAtom == 0 || len(p.overflow) > 0 {
+		return
+	}
+	for i := len(p.tagStack) - 1; i >= 0; i-- {
+		if p.tagStack[i] == token.DataAtom {
+			p.lintUnclosed(i+1, "before </"+token.Data+">")
+			return
+		}
+	}
+	if !voidElements[token.DataAtom] {
+		p.problem("</%s> does not close an open element", token.Data)
+	}
+}
+
+// lintUnclosed reports the elements in the tag stack from index start that
+// are left open, other than those whose end tag is optional.
+func (p *parser) lintUnclosed(start int, where string) {
+	for _, tag := range p.tagStack[start:] {
+		if tag != 0 && !optionalEndTags[tag] {
+			p.problem("<%s> is not closed %s", tag, where)
+		}
+	}
+}
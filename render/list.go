@@ -0,0 +1,105 @@
+package render
+
+import (
+	"strconv"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// bullet marks the items of unordered lists.
+const bullet = "• "
+
+// listElement is a list (<ul> or <ol>) that is being parsed.
+type listElement struct {
+	ordered bool
+
+	// number is that of the last item of an ordered list, and depth the
+	// size of the tag stack when the list started.
+	number int
+	depth  int
+
+	// index is the position of an ordered list among those in the
+	// document, and indent the width its items are indented by.
+	index  int
+	indent int
+}
+
+// startList starts a list. Ordered lists count their items from their start
+// attribute, or from one.
+func (p *parser) startList(token html.Token) {
+	if token.Type != html.StartTagToken {
+		return
+	}
+	l := listElement{ordered: token.DataAtom == atom.Ol, depth: len(p.tagStack)}
+	if n, err := strconv.Atoi(tokenAttr(token, "start")); err == nil && l.ordered {
+		l.number = n - 1
+	}
+	if l.ordered {
+		l.index, l.indent = len(p.markerWidths), indentElements[atom.Ol]
+		if l.index < len(p.markerIndents) {
+			l.indent = max(l.indent, p.markerIndents[l.index])
+		}
+		p.markerWidths = append(p.markerWidths, 0)
+	}
+	p.lists = append(p.lists, l)
+}
+
+// listIndent returns how much further than indentElements gives them the
+// ordered lists that are open indent their items, to fit their numbers.
+func (p *parser) listIndent() int {
+	n := 0
+	for _, l := range p.lists {
+		if l.ordered && l.depth <= len(p.tagStack) {
+			n += l.indent - indentElements[atom.Ol]
+		}
+	}
+
+	return n
+}
+
+// endLists ends the lists whose elements have been closed.
+func (p *parser) endLists() {
+	for n := len(p.lists); n > 0 && len(p.tagStack) < p.lists[n-1].depth; n-- {
+		p.lists = p.lists[:n-1]
+	}
+}
+
+// startItem marks the start of an item of the innermost list, with a bullet
+// or its number. The marker hangs in the list's indent, to the left of the
+// item's text, so that the item's lines are aligned under its first. An item
+// given a value attribute is numbered by it, and those after it from it.
+func (p *parser) startItem(token html.Token) {
+	if len(p.lists) == 0 {
+		return
+	}
+	l := &p.lists[len(p.lists)-1]
+	marker := bullet
+	if l.ordered {
+		l.number++
+		if n, err := strconv.Atoi(tokenAttr(token, "value")); err == nil {
+			l.number = n
+		}
+		marker = strconv.Itoa(l.number) + ". "
+	}
+
+	c := &p.doc
+	width := len([]rune(marker))
+	if l.ordered {
+		p.markerWidths[l.index] = max(p.markerWidths[l.index], width)
+		p.markersOverflowed = p.markersOverflowed || width > l.indent
+	}
+	x := max(c.lmargin-width, c.margin)
+
+	// An empty item leaves its marker on a line of its own, rather than
+	// having it overwritten by the next.
+	if i := c.row*c.Width + x; i < len(c.Cells) && c.Cells[i].Ch != 0 {
+		c.newline()
+	}
+	c.style(p.tagStack, p.colors)
+	for _, r := range marker {
+		c.setCell(x, c.row, r, c.fg, c.bg)
+		x++
+	}
+	c.col = max(c.lmargin, x)
+}
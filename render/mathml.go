@@ -0,0 +1,202 @@
+package render
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// mathNode is an element, or text, within a MathML expression.
+type mathNode struct {
+	name     string
+	text     string
+	attrs    []html.Attribute
+	children []*mathNode
+}
+
+// attr returns the value of the named attribute of a math node.
+func (n *mathNode) attr(key string) string {
+	for _, a := range n.attrs {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// readMath reads the tokens of a MathML expression up to and including the
+// closing </math> tag, and returns the expression as a tree rooted at the
+// <math> element described by start.
+func readMath(z *html.Tokenizer, start html.Token) *mathNode {
+	root := &mathNode{name: start.Data, attrs: start.Attr}
+	stack := []*mathNode{root}
+	for len(stack) > 0 {
+		tokenType := z.Next()
+		token := z.Token()
+		parent := stack[len(stack)-1]
+		switch tokenType {
+		case html.ErrorToken:
+			return root
+		case html.StartTagToken:
+			n := &mathNode{name: token.Data, attrs: token.Attr}
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case html.SelfClosingTagToken:
+			n := &mathNode{name: token.Data, attrs: token.Attr}
+			parent.children = append(parent.children, n)
+		case html.TextToken:
+			if text := strings.TrimSpace(token.Data); text != "" {
+				parent.children = append(parent.children, &mathNode{text: text})
+			}
+		case html.EndTagToken:
+			// Close the nearest matching element, tolerating unclosed
+			// children.
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name == token.Data {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+	}
+
+	return root
+}
+
+// mathToText returns a linear, plain text approximation of a MathML
+// expression, e.g. "x^2 + sqrt(y)".
+func mathToText(n *mathNode) string {
+	text := strings.TrimSpace(mathText(n))
+	if text == "" {
+		text = n.attr("alttext")
+	}
+
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// mathText converts a math node to text.
+func mathText(n *mathNode) string {
+	if n.name == "" {
+		return n.text
+	}
+
+	arg := func(i int) string {
+		if i < len(n.children) {
+			return mathText(n.children[i])
+		}
+		return ""
+	}
+
+	switch n.name {
+	case "mo":
+		return mathOperator(strings.TrimSpace(childText(n)))
+	case "mi", "mn", "mtext", "ms":
+		return childText(n)
+	case "msup", "mover":
+		return group(arg(0)) + "^" + group(arg(1))
+	case "msub", "munder":
+		return group(arg(0)) + "_" + group(arg(1))
+	case "msubsup", "munderover":
+		return group(arg(0)) + "_" + group(arg(1)) + "^" + group(arg(2))
+	case "mfrac":
+		return group(arg(0)) + "/" + group(arg(1))
+	case "msqrt":
+		return "sqrt(" + strings.TrimSpace(childText(n)) + ")"
+	case "mroot":
+		return "root(" + strings.TrimSpace(arg(0)) + ", " + strings.TrimSpace(arg(1)) + ")"
+	case "mfenced":
+		open, close, sep := "(", ")", ","
+		if v := n.attr("open"); v != "" {
+			open = v
+		}
+		if v := n.attr("close"); v != "" {
+			close = v
+		}
+		if v := []rune(strings.TrimSpace(n.attr("separators"))); len(v) > 0 {
+			sep = string(v[0])
+		}
+		var parts []string
+		for _, c := range n.children {
+			parts = append(parts, strings.TrimSpace(mathText(c)))
+		}
+		return open + strings.Join(parts, sep+" ") + close
+	case "semantics":
+		// Only the first child is presentation markup; the rest are
+		// annotations.
+		return arg(0)
+	case "annotation", "annotation-xml", "none", "mprescripts":
+		return ""
+	case "mspace":
+		return " "
+	}
+
+	return childText(n)
+}
+
+// childText concatenates the text of a math node's children.
+func childText(n *mathNode) string {
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(mathText(c))
+	}
+
+	return b.String()
+}
+
+// mathOperator spaces an operator according to its role. Binary operators and
+// relations are surrounded by spaces; brackets and separators are not.
+func mathOperator(op string) string {
+	switch op {
+	case "\u2061", "\u2062", "\u2063":
+		// Function application, invisible times and invisible separator.
+		return ""
+	case "(", ")", "[", "]", "{", "}", "|", "!", "'":
+		return op
+	case ",", ";":
+		return op + " "
+	}
+
+	return " " + op + " "
+}
+
+// group wraps an operand in parentheses unless it is a single term.
+func group(s string) string {
+	s = strings.TrimSpace(s)
+	simple := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' {
+			simple = false
+			break
+		}
+	}
+	if simple || isBracketed(s) {
+		return s
+	}
+
+	return "(" + s + ")"
+}
+
+// isBracketed reports whether s is entirely enclosed by a single pair of
+// parentheses.
+func isBracketed(s string) bool {
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return false
+	}
+
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i < len(s)-1 {
+				return false
+			}
+		}
+	}
+
+	return depth == 0
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestMathToText(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{
+			"SupAndSqrt",
+			"<math><msup><mi>x</mi><mn>2</mn></msup><mo>+</mo><msqrt><mi>y</mi></msqrt></math>",
+			"x^2 + sqrt(y)",
+		},
+		{
+			"Frac",
+			"<math><mfrac><mrow><mi>a</mi><mo>+</mo><mi>b</mi></mrow><mn>2</mn></mfrac></math>",
+			"(a + b)/2",
+		},
+		{
+			"SubAndFunction",
+			"<math><mi>f</mi><mo>&#x2061;</mo><mo>(</mo><msub><mi>x</mi><mi>i</mi></msub><mo>)</mo></math>",
+			"f(x_i)",
+		},
+		{
+			"Semantics",
+			"<math><semantics><mi>π</mi><annotation encoding=\"application/x-tex\">\\pi</annotation></semantics></math>",
+			"π",
+		},
+		{
+			"AltText",
+			"<math alttext=\"E = mc^2\"></math>",
+			"E = mc^2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			z := html.NewTokenizer(strings.NewReader(tc.src))
+			z.Next()
+			if text := mathToText(readMath(z, z.Token())); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestParseMath(t *testing.T) {
+	src := "<p>Let <math><msup><mi>x</mi><mn>2</mn></msup></math> be <i>big</i>.</p>"
+	doc, err := Parse(strings.NewReader(src), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, text := "  Let x^2 be big.", rowText(doc, 0); text != exp {
+		t.Errorf(expFormat, exp, text)
+	}
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	"path"
+	"strings"
+
+	"github.com/taylorskalyo/goreader/epub"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// mediaElement is an <audio> or <video> element being parsed.
+type mediaElement struct {
+	kind string
+	src  string
+
+	// depth is the size of the tag stack when the element started.
+	depth int
+
+	// fallback holds the element's content, which readers show when they
+	// cannot play it.
+	fallback strings.Builder
+}
+
+// startMedia starts an <audio> or <video> element. Its content is read as
+// fallback text rather than displayed.
+func (p *parser) startMedia(token html.Token) {
+	m := &mediaElement{
+		kind:  token.Data,
+		src:   tokenAttr(token, "src"),
+		depth: len(p.tagStack),
+	}
+	p.media = m
+	if token.Type == html.SelfClosingTagToken {
+		p.endMedia()
+	}
+}
+
+// endMedia ends the current <audio> or <video> element.
+func (p *parser) endMedia() {
+	p.doc.startLine()
+	row := p.doc.row
+	p.doc.style(p.tagStack, p.colors)
+	p.doc.appendMedia(p.media)
+	p.addResource(p.media.src, row)
+	p.media = nil
+}
+
+// handleMediaTag reads a tag within an <audio> or <video> element, taking its
+// source from the first <source> element if it has none of its own.
+func (p *parser) handleMediaTag(token html.Token) {
+	if token.DataAtom == atom.Source && p.media.src == "" {
+		p.media.src = tokenAttr(token, "src")
+	}
+}
+
+// Resource is an image, audio or video file shown in a document, as ASCII art,
+// a caption or a placeholder that covers rows Row to End.
+type Resource struct {
+	Row, End int
+	Item     epub.Item
+}
+
+// addResource records that the file at src is shown from row to the current
+// row, if it is one of the book's items.
+func (p *parser) addResource(src string, row int) {
+	if item, ok := p.item(src); ok {
+		p.doc.Resources = append(p.doc.Resources, Resource{Row: row, End: p.doc.row, Item: item})
+	}
+}
+
+// appendMedia appends a placeholder for a media element on a line of its own,
+// e.g. "[audio: chapter1.mp3]". It names the element's source, or else
+// describes it with its fallback text.
+func (c *Document) appendMedia(m *mediaElement) {
+	label := strings.Join(strings.Fields(m.fallback.String()), " ")
+	if m.src != "" {
+		label = path.Base(m.src)
+	}
+	text := "[" + m.kind + "]"
+	if label != "" {
+		text = "[" + m.kind + ": " + label + "]"
+	}
+
+	c.startLine()
+	c.appendText(text)
+	c.startLine()
+}
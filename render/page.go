@@ -0,0 +1,69 @@
+package render
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Page marks where a page of the book's print edition begins.
+type Page struct {
+	Row   int
+	Label string
+}
+
+// tokenAttr returns the value of the named attribute of a token.
+func tokenAttr(token html.Token, key string) string {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+
+	return ""
+}
+
+// pageBreak returns the label of the print page that starts at a page break
+// element, i.e. one with an epub:type of "pagebreak" or a role of
+// "doc-pagebreak". It returns false if the element does not mark a page break
+// or its page is unlabeled.
+func pageBreak(token html.Token) (string, bool) {
+	if !hasWord(tokenAttr(token, "epub:type"), "pagebreak") &&
+		!hasWord(tokenAttr(token, "role"), "doc-pagebreak") {
+		return "", false
+	}
+
+	label := tokenAttr(token, "aria-label")
+	if label == "" {
+		label = tokenAttr(token, "title")
+	}
+	label = strings.Trim(strings.TrimSpace(label), "[]")
+
+	return label, label != ""
+}
+
+// breaksBefore reports whether an element's style asks for a page break
+// before it, e.g. "page-break-before: always".
+func (p *parser) breaksBefore(token html.Token) bool {
+	switch val, _ := p.cssValue(token, "page-break-before"); val {
+	case "always", "left", "right":
+		return true
+	}
+	switch val, _ := p.cssValue(token, "break-before"); val {
+	case "page", "left", "right":
+		return true
+	}
+
+	return false
+}
+
+// hasWord reports whether word is one of the space-separated words in s.
+func hasWord(s, word string) bool {
+	for _, w := range strings.Fields(s) {
+		if w == word {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,60 @@
+package render
+
+import "sort"
+
+// Paragraph is a block of text within a cell buffer document, such as a
+// paragraph, heading or list item, that covers rows Row to End. Blocks start
+// wherever text starts on a new line for a new element (see startLine and
+// startParagraph), so the lines of a single block separated by line breaks,
+// such as those of a stanza, belong to one paragraph.
+type Paragraph struct {
+	Row, End int
+}
+
+// addParagraph records row as the start of a new paragraph. A paragraph is
+// only recorded once for each row.
+func (c *Document) addParagraph(row int) {
+	if n := len(c.Paragraphs); n > 0 && c.Paragraphs[n-1].Row >= row {
+		return
+	}
+	c.Paragraphs = append(c.Paragraphs, Paragraph{Row: row})
+}
+
+// endParagraphs ends each paragraph where the next starts, leaving out the
+// blank rows between them, and drops those that hold no text.
+func (c *Document) endParagraphs() {
+	if len(c.Paragraphs) == 0 || c.Paragraphs[0].Row > 0 {
+		c.Paragraphs = append([]Paragraph{{Row: 0}}, c.Paragraphs...)
+	}
+
+	paragraphs := c.Paragraphs[:0]
+	for i, par := range c.Paragraphs {
+		par.End = c.Rows() - 1
+		if i+1 < len(c.Paragraphs) {
+			par.End = min(c.Paragraphs[i+1].Row-1, par.End)
+		}
+		for par.Row <= par.End && c.blankRow(par.Row) {
+			par.Row++
+		}
+		for par.End >= par.Row && c.blankRow(par.End) {
+			par.End--
+		}
+		if par.Row <= par.End {
+			paragraphs = append(paragraphs, par)
+		}
+	}
+	c.Paragraphs = paragraphs
+}
+
+// Paragraph returns the paragraph that holds row, or else the first one after
+// it. It returns false if there is no paragraph at or after row.
+func (c Document) Paragraph(row int) (Paragraph, bool) {
+	i := sort.Search(len(c.Paragraphs), func(i int) bool {
+		return c.Paragraphs[i].End >= row
+	})
+	if i == len(c.Paragraphs) {
+		return Paragraph{}, false
+	}
+
+	return c.Paragraphs[i], true
+}
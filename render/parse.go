@@ -0,0 +1,1591 @@
+/*
+Package render lays out the HTML content of books as text in a grid of
+terminal cells, rendering images in color or as ASCII art.
+*/
+
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	_ "image/jpeg"
+	_ "image/png"
+
+	"github.com/nfnt/resize"
+	termbox "github.com/nsf/termbox-go"
+	"github.com/taylorskalyo/goreader/epub"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/text/unicode/norm"
+)
+
+type parser struct {
+	tagStack  []atom.Atom
+	tokenizer *html.Tokenizer
+	doc       Document
+	items     []epub.Item
+
+	// splitLevel is the deepest heading level at which the document is split
+	// into sections. Zero disables splitting.
+	splitLevel int
+
+	// images controls whether images are rendered as ASCII art, and
+	// imageOpts how they are rendered.
+	images    bool
+	imageOpts ImageOptions
+
+	// imageCaptionFormat is the format of the captions shown for images.
+	imageCaptionFormat string
+
+	// thumbnailWidth is the most columns images are rendered to, if it is
+	// positive, and thumbnailHint follows those rendered as thumbnails.
+	thumbnailWidth int
+	thumbnailHint  string
+
+	// highlight controls whether code blocks that declare a language are
+	// syntax highlighted. code holds the block currently being buffered.
+	highlight bool
+	code      *codeBlock
+
+	// media is the <audio> or <video> element currently being parsed, if
+	// any.
+	media *mediaElement
+
+	// tooltip is the element with a title currently being parsed, if any.
+	// expandAbbreviations controls whether abbreviations are followed by
+	// their expansion, and abbreviations records those that have been.
+	tooltip             *tooltipElement
+	expandAbbreviations bool
+	abbreviations       map[string]bool
+
+	// link is the hyperlink currently being parsed, if any, and ids records
+	// the source offset of each element with an id.
+	link *linkElement
+	ids  map[string]int
+
+	// divisions are the elements with a structural epub:type being parsed,
+	// innermost last, and sectionBreaks controls whether they are set apart
+	// from the text before them.
+	divisions     []division
+	sectionBreaks bool
+
+	// lists are the lists being parsed, innermost last.
+	lists []listElement
+
+	// markerIndents are the widths ordered lists are indented by, by the
+	// order they start in, where they are wider than indentElements gives
+	// them. markerWidths are the widths of the widest markers of the
+	// ordered lists parsed so far, and markersOverflowed whether any was
+	// wider than its list's indent.
+	markerIndents     []int
+	markerWidths      []int
+	markersOverflowed bool
+
+	// pre is the preformatted element currently being parsed, if any.
+	pre *preElement
+
+	// heading is the heading element currently being parsed, if any, and
+	// headingDepth the size of the tag stack when it started.
+	heading      *Heading
+	headingDepth int
+
+	// text buffers adjacent text tokens, which may be separated by break
+	// hints (e.g. <wbr>), until they can be appended as a whole.
+	text strings.Builder
+
+	// italicMarker, when set, is written around italic text in place of an
+	// attribute.
+	italicMarker string
+
+	// layout controls how paragraphs are set out.
+	layout Layout
+
+	// container records whether a container element (see
+	// startContainer) has been started, and containerRow and containerCol
+	// the position of the cursor when it was.
+	container                  bool
+	containerRow, containerCol int
+
+	// ruby is how ruby annotations are shown.
+	ruby string
+
+	// rules holds the rules of the document's stylesheets, and transforms
+	// the text-transform of each element in the tag stack.
+	rules      []styleRule
+	transforms []string
+
+	// overflow holds the open elements that were nested too deeply to be
+	// pushed onto the tag stack, from the outermost, and overflowed the
+	// number of each of them.
+	overflow   []atom.Atom
+	overflowed map[atom.Atom]int
+
+	// colors holds the attribute given by each element in the tag stack,
+	// for those that set one (e.g. <font color="red">, or the bold of a
+	// table's header cells).
+	colors []termbox.Attribute
+
+	// spans records, for each open span, whether it is a Kobo span (see
+	// koboSpan).
+	spans []bool
+
+	// read is the number of bytes of the source tokenized so far, and
+	// textOffset the position in it of the text buffered in text.
+	read       int
+	textOffset int
+
+	// raw is the markup of the current token when it is a start tag, as it
+	// was read. Some versions of the tokenizer lowercase attribute names in
+	// place as they parse a tag, which would turn an SVG's viewBox into
+	// viewbox.
+	raw []byte
+
+	// details overrides, by index, whether <details> elements are expanded,
+	// and expandDetails is whether the others are by default. collapsed is
+	// the size of the tag stack when a collapsed element was pushed, or zero.
+	details       map[int]bool
+	expandDetails bool
+	collapsed     int
+
+	// hiddenDepth is the size of the tag stack when an element with the
+	// hidden attribute was pushed, or zero.
+	hiddenDepth int
+
+	// linting controls whether problems with the source are recorded (see
+	// lint). line is the line of the source the current token starts on,
+	// and lines the number of line feeds tokenized so far. invalidText
+	// records that invalid text has been reported.
+	linting     bool
+	line, lines int
+	invalidText bool
+}
+
+// Document is a book's text laid out as a grid of terminal cells, Width cells
+// wide.
+type Document struct {
+	Cells   []termbox.Cell
+	Width   int
+	lmargin int
+	rmargin int
+	col     int
+	row     int
+	fg, bg  termbox.Attribute
+
+	// margin is the left margin outside any indented block (see indent).
+	margin int
+
+	// lineSpacing is the number of blank rows inserted between lines.
+	lineSpacing int
+
+	// rows is the number of rows that have been written to.
+	rows int
+
+	// offset is the position in the source of the text being laid out, and
+	// anchors records it for the first text on each row.
+	offset  int
+	anchors []anchor
+
+	// breaks counts the line breaks (<br>) since text was last appended,
+	// and maxBreaks is the most blank lines they may leave (see
+	// lineBreaks).
+	breaks, maxBreaks int
+
+	// Sections holds the start of each section, delimited by headings and
+	// by elements with a structural epub:type, in ascending order of row.
+	Sections []Section
+
+	// Type is the structural epub:type of the document's <body> (e.g.
+	// "chapter"), or empty if it has none.
+	Type string
+
+	// Headings lists every heading in the document, in order.
+	Headings []Heading
+
+	// Paragraphs lists the blocks of text in the document, in order.
+	Paragraphs []Paragraph
+
+	// Pages lists the print pages that start within the document, in order.
+	Pages []Page
+
+	// Resources lists the images, audio and video shown in the document, in
+	// order.
+	Resources []Resource
+
+	// Details lists every <details> element in the document, in order.
+	// Those within collapsed content have a Row of -1.
+	Details []Detail
+
+	// Tooltips lists the titles of inline elements in the document, such as
+	// the expansions of abbreviations, in order.
+	Tooltips []Tooltip
+
+	// Links lists the hyperlinks in the document, in order, and IDs maps the
+	// id of each element in the document, and the name of each anchor, to
+	// the row it starts on.
+	Links []Link
+	IDs   map[string]int
+
+	// Problems lists the problems found in the document's source, in
+	// order, when it is parsed with Options.Lint.
+	Problems []Problem
+
+	// theme gives the attributes text is displayed with, and italic the
+	// attribute of italic text. noColor leaves the colors of cells out.
+	theme   Theme
+	italic  termbox.Attribute
+	noColor bool
+
+	// gapRow and gapCol are the position of the cursor just after the last
+	// appended word. The space that separates it from the next word is only
+	// laid out once that word is appended on the same line, so that lines
+	// never end in a space. joined reports whether the text the word ended
+	// did not end in a space, so that text following it without one (e.g.
+	// punctuation after an inline element) is joined to it.
+	gapRow, gapCol int
+	joined         bool
+
+	// centered controls whether lines are centered when they end.
+	centered bool
+
+	// balanced controls whether runs of wrapped text are balanced when they
+	// end. run is set while a run is under way, starting at runRow and
+	// runCol; runSplit records that it cannot be balanced.
+	balanced       bool
+	run, runSplit  bool
+	runRow, runCol int
+}
+
+// Heading is a heading element within a cell buffer document.
+type Heading struct {
+	Row   int
+	Level int
+	Title string
+}
+
+// zeroWidthSpace marks a position within a word where it may be broken across
+// lines.
+const zeroWidthSpace = '\u200b'
+
+// headingLevels maps heading elements to their level.
+var headingLevels = map[atom.Atom]int{
+	atom.H1: 1,
+	atom.H2: 2,
+	atom.H3: 3,
+	atom.H4: 4,
+	atom.H5: 5,
+	atom.H6: 6,
+}
+
+// setCell changes a cell's attributes in the cell buffer document at the given
+// position.
+func (c *Document) setCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	c.grow(y*c.Width + x)
+	if c.noColor {
+		fg, bg = fg&^colorMask, bg&^colorMask
+	}
+	c.Cells[y*c.Width+x] = termbox.Cell{Ch: ch, Fg: fg, Bg: bg}
+	if ch != ' ' {
+		c.addAnchor(y)
+	}
+	if y >= c.rows {
+		c.rows = y + 1
+	}
+}
+
+// grow pads Cells until it holds index i.
+func (c *Document) grow(i int) {
+	// Grow in steps of 1024 when out of space.
+	for i >= len(c.Cells) {
+		c.Cells = append(c.Cells, make([]termbox.Cell, 1024)...)
+	}
+}
+
+// Rows returns the number of rows the document's text takes up. It does not
+// count the blank rows that Cells is padded with.
+func (c Document) Rows() int {
+	return c.rows
+}
+
+// trim drops the rows at the end of the document that have been written to but
+// hold only spaces (e.g. from the blank lines of preformatted text), along
+// with the padding after them.
+func (c *Document) trim() {
+	for c.rows > 0 && c.blankRow(c.rows-1) {
+		c.rows--
+	}
+	c.Cells = c.Cells[:min(c.rows*c.Width, len(c.Cells))]
+}
+
+// blankRow reports whether row y of the document holds only spaces.
+func (c *Document) blankRow(y int) bool {
+	for _, cell := range c.Cells[y*c.Width : min((y+1)*c.Width, len(c.Cells))] {
+		if cell.Ch != 0 && cell.Ch != ' ' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// BookRows returns the number of rows the chapters in a book's spine take up
+// altogether when laid out with opts.
+func BookRows(book *epub.Rootfile, opts Options) (int, error) {
+	var rows int
+	for _, itemref := range book.Spine.Itemrefs {
+		doc, err := parseItem(itemref, book.Manifest.Items, opts)
+		if err != nil {
+			return rows, err
+		}
+		rows += doc.Rows()
+	}
+
+	return rows, nil
+}
+
+// parseItem opens a spine item and lays it out with opts.
+func parseItem(itemref epub.Itemref, items []epub.Item, opts Options) (Document, error) {
+	f, err := itemref.Open()
+	if err != nil {
+		return Document{}, err
+	}
+	defer f.Close()
+
+	return Parse(f, items, opts)
+}
+
+// scanWords is a split function for a Scanner that returns space-separated
+// words. Unlike bufio.ScanWords(), scanWords only splits on spaces (i.e. not
+// newlines, tabs, or other whitespace). A space byte cannot be part of the
+// encoding of any other character, so words are split at bytes rather than
+// decoded runes; text that is not valid UTF-8 is split as if it were, and its
+// invalid bytes are left for appendText to replace.
+func scanWords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	// Skip leading spaces.
+	start := 0
+	for start < len(data) && data[start] == ' ' {
+		start++
+	}
+
+	// Scan until space, marking end of word.
+	if i := bytes.IndexByte(data[start:], ' '); i >= 0 {
+		return start + i + 1, data[start : start+i], nil
+	}
+
+	// If we're at EOF, we have a final, non-empty, non-terminated word. Return
+	// it.
+	if atEOF && len(data) > start {
+		return len(data), data[start:], nil
+	}
+
+	// Request more data.
+	return start, nil, nil
+}
+
+// newlines replaces Windows (CRLF) and lone carriage return line endings with
+// line feeds.
+var newlines = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// normalizeNewlines returns s with its line endings replaced by line feeds.
+// The tokenizer normalizes those in the source, but carriage returns may
+// still be written as character references (&#13;), which would otherwise
+// be displayed as stray glyphs.
+func normalizeNewlines(s string) string {
+	if !strings.ContainsRune(s, '\r') {
+		return s
+	}
+
+	return newlines.Replace(s)
+}
+
+// colorMask selects the color of an attribute, leaving out text attributes
+// such as bold.
+const colorMask = termbox.AttrBold - 1
+
+// style sets the foreground/background attributes for future cells in the cell
+// buffer document based on HTML tags in the tag stack, and the colors they
+// set. Text attributes of nested elements are combined, while colors of inner
+// elements replace those of outer ones.
+func (c *Document) style(tags []atom.Atom, colors []termbox.Attribute) {
+	fg := termbox.ColorDefault
+	apply := func(a termbox.Attribute) {
+		fg = Overlay(fg, a)
+	}
+	apply(c.theme.Body)
+	for i, tag := range tags {
+		if i < len(colors) {
+			apply(colors[i])
+		}
+		switch tag {
+		case atom.B, atom.Strong, atom.Big, atom.Summary:
+			apply(c.theme.Bold)
+		case atom.Small:
+			apply(termbox.AttrDim)
+		case atom.I, atom.Em:
+			apply(c.italic)
+		case atom.Title:
+			apply(c.theme.Title)
+		case atom.A:
+			apply(c.theme.Link)
+		case atom.Mark:
+			apply(c.theme.Highlight)
+		case atom.Blockquote:
+			apply(c.theme.Quote)
+		default:
+			if level, ok := headingLevels[tag]; ok {
+				apply(c.theme.Headings[level-1])
+			}
+		}
+	}
+	c.fg = fg
+}
+
+// italicColors are the colors that italic text may be displayed in.
+var italicColors = map[string]termbox.Attribute{
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+}
+
+// italicStyle interprets the italic setting. It returns the attribute italic
+// text is displayed with, or else the marker it is wrapped in.
+func italicStyle(s string) (termbox.Attribute, string) {
+	switch s {
+	case "":
+		return italicStyle(DefaultItalic)
+	case "bold":
+		return termbox.AttrBold, ""
+	case "underline":
+		return termbox.AttrUnderline, ""
+	case "reverse":
+		return termbox.AttrReverse, ""
+	}
+	if color, ok := italicColors[s]; ok {
+		return color, ""
+	}
+
+	return termbox.ColorDefault, s
+}
+
+// within reports whether an element is open in the tag stack.
+func (p *parser) within(tag atom.Atom) bool {
+	for _, t := range p.tagStack {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// italicDepth returns the number of italic elements in the tag stack.
+func (p *parser) italicDepth() int {
+	n := 0
+	for _, tag := range p.tagStack {
+		if tag == atom.I || tag == atom.Em {
+			n++
+		}
+	}
+
+	return n
+}
+
+// newline moves the cell buffer document's cursor to the start of the next
+// line, ending the current run of wrapped text.
+func (c *Document) newline() {
+	c.balance()
+	c.wrap()
+}
+
+// textWidth returns the number of columns available for text between the
+// margins.
+func (c *Document) textWidth() int {
+	return c.Width - c.lmargin - c.rmargin
+}
+
+// appendText appends text to the cell buffer document.
+func (c *Document) appendText(str string) {
+	str = stripBidi(normalizeNewlines(str))
+	c.lineBreaks()
+	if c.col < c.lmargin {
+		c.col = c.lmargin
+	}
+	joined := c.joined && !strings.HasPrefix(str, " ")
+	c.joined = !strings.HasSuffix(str, " ")
+	scanner := bufio.NewScanner(strings.NewReader(str))
+	scanner.Split(scanWords)
+	base, pos := c.offset, 0
+	defer func() { c.offset = base }()
+	for scanner.Scan() {
+		// Words are anchored at their position within the source text.
+		if i := strings.Index(str[pos:], scanner.Text()); i >= 0 {
+			pos += i
+			c.offset = base + pos
+		}
+		if c.row == c.gapRow && c.col == c.gapCol && c.col > c.lmargin && !joined {
+			c.col++
+		}
+		joined = false
+		// Words that do not fit on the current line may be broken at
+		// zero-width spaces and around wide characters (see
+		// breakSegments). Only the part of a word before a line feed needs
+		// to fit. Parts wider than the text column would not fit on any
+		// line, so they are started where the cursor is and broken at the
+		// right margin. Each byte that is not valid UTF-8 is shown as the
+		// replacement character (U+FFFD).
+		for i, seg := range breakSegments(scanner.Text()) {
+			first, _, _ := strings.Cut(seg, "\n")
+			width := stringWidth(first)
+			if width > c.Width-c.rmargin-c.col && width <= c.textWidth() && (i == 0 || c.col > c.lmargin) {
+				c.wrap()
+				c.runSplit = c.runSplit || i > 0
+			}
+			if width > c.Width-c.rmargin-c.col {
+				c.runSplit = true
+			}
+			for _, r := range seg {
+				if r == '\n' {
+					c.newline()
+					continue
+				}
+				if c.col+runeWidth(r) > c.Width-c.rmargin && c.col > c.lmargin {
+					c.wrap()
+				}
+				c.startRun()
+				c.putRune(r, c.fg)
+			}
+		}
+		if c.col != c.lmargin {
+			c.gapRow, c.gapCol = c.row, c.col
+		}
+	}
+}
+
+// appendSuffix appends text directly after the last appended word, without
+// separating space.
+func (c *Document) appendSuffix(str string) {
+	c.lineBreaks()
+	for _, r := range str {
+		c.putRune(r, c.fg)
+	}
+	c.gapRow, c.gapCol = c.row, c.col
+}
+
+// appendBlock appends rows of cells (e.g. an image) to the cell buffer
+// document, starting on a new line. Rows are not wrapped and no line spacing
+// is added between them. Cells without colors take those of the text.
+func (c *Document) appendBlock(rows [][]termbox.Cell) {
+	c.lineBreaks()
+	if c.col > c.lmargin {
+		c.newline()
+	}
+	for _, row := range rows {
+		c.col = c.lmargin
+		for _, cell := range row {
+			if cell.Fg == termbox.ColorDefault && cell.Bg == termbox.ColorDefault {
+				cell.Fg, cell.Bg = c.fg, c.bg
+			}
+			c.setCell(c.col, c.row, cell.Ch, cell.Fg, cell.Bg)
+			c.col++
+		}
+		c.row++
+	}
+	c.col = c.lmargin
+}
+
+// DefaultWidth is the width of documents whose options do not specify one.
+const DefaultWidth = 80
+
+// MinTextWidth is the fewest columns left for text between a document's
+// margins. Margins are narrowed when they would leave less.
+const MinTextWidth = 10
+
+// DefaultMaxBlankLines is the most blank lines a run of line breaks leaves
+// when its options do not specify.
+const DefaultMaxBlankLines = 2
+
+// DefaultImageCaption is the format of image captions when the options do not
+// specify one.
+const DefaultImageCaption = "Alt text: {alt}"
+
+// DefaultItalic is how italic text is shown when its options do not specify.
+const DefaultItalic = "underline"
+
+// Options controls how a document is laid out.
+type Options struct {
+	// Width is the number of columns in the document, including margins.
+	Width int
+
+	// Margin is the number of blank columns on either side of the text. It
+	// is narrowed to leave at least MinTextWidth columns for text.
+	Margin int
+
+	// Theme gives the attributes text is displayed with. The default theme
+	// is used when it is nil.
+	Theme *Theme
+
+	// NoColor leaves colors out of the document, for terminals that cannot
+	// show them. Only text attributes such as bold are kept, and the theme
+	// is made monochrome (see Theme.Monochrome).
+	NoColor bool
+
+	// Gutter is the number of blank columns set aside before the left
+	// margin, e.g. for line numbers. They are added to Width.
+	Gutter int
+
+	// LineSpacing is the number of blank rows inserted between lines.
+	LineSpacing int
+
+	// Split is the deepest heading level at which the document is split
+	// into sections. Zero disables splitting.
+	Split int
+
+	// Images controls whether images are rendered. Brightness and Contrast
+	// adjust them, and ImageMode is how they are rendered, as for
+	// ImageOptions.
+	Images     bool
+	Brightness int
+	Contrast   int
+	ImageMode  string
+
+	// Highlight controls whether code blocks that declare their language
+	// (e.g. class="language-go") are syntax highlighted.
+	Highlight bool
+
+	// Italic is how italic and emphasized text is shown: a color (e.g.
+	// "cyan"), "bold", "underline", "reverse", or a marker written around
+	// the text (e.g. "/" for /text/).
+	Italic string
+
+	// Layout is the name of the layout preset paragraphs are set out with.
+	// Unknown names select the default.
+	Layout string
+
+	// Wrap is the line breaking algorithm, WrapGreedy or WrapBalanced. Other
+	// values select WrapGreedy.
+	Wrap string
+
+	// Ruby is how ruby annotations (e.g. furigana) are shown: RubyInline or
+	// RubyHide. Other values select RubyInline.
+	Ruby string
+
+	// ImageCaption is the format of the caption shown for images, e.g.
+	// "[Figure: {alt}]". The placeholders {alt}, {src} and {title} are
+	// replaced by the image's alt text (or title or label, in its absence),
+	// source and title. It is left out when none of the placeholders have a
+	// value, or, for formats without placeholders, when the image is
+	// rendered as ASCII art. An empty format selects DefaultImageCaption.
+	ImageCaption string
+
+	// ThumbnailWidth is the most columns images that are not kept within
+	// the flow of the text are rendered to as ASCII art, so that they do
+	// not dominate the page. Images rendered narrower than the text are
+	// followed by ThumbnailHint, e.g. "[press o to view]", on a line of its
+	// own. Zero or less renders images at the full width of the text.
+	ThumbnailWidth int
+	ThumbnailHint  string
+
+	// MaxBlankLines is the most blank lines left by a run of consecutive
+	// line breaks (<br>), which are often used in place of paragraphs or
+	// to separate stanzas. Longer runs are shortened. Zero or less selects
+	// DefaultMaxBlankLines.
+	MaxBlankLines int
+
+	// ExpandDetails controls whether the content of <details> elements is
+	// shown by default, rather than only their summary. Details overrides
+	// this for the elements at the given indices of Document.Details.
+	ExpandDetails bool
+	Details       map[int]bool
+
+	// ExpandAbbreviations controls whether the first occurrence of each
+	// abbreviation (<abbr title="...">) is followed by its expansion in
+	// parentheses.
+	ExpandAbbreviations bool
+
+	// SectionBreaks controls whether elements with a structural epub:type
+	// (e.g. <section epub:type="chapter">) are set apart from the text
+	// before them: parts and volumes by a rule, and other divisions by a
+	// blank row.
+	SectionBreaks bool
+
+	// Lint controls whether problems with the document's source, such as
+	// missing images or mismatched tags, are recorded in
+	// Document.Problems.
+	Lint bool
+}
+
+// Image modes, as named by ImageOptions.Mode.
+const (
+	// ImageASCII renders images as ASCII art, in shades of gray.
+	ImageASCII = "ascii"
+
+	// ImageBlocks renders images in color, with two pixels to each cell,
+	// one above the other (see renderBlocks).
+	ImageBlocks = "blocks"
+)
+
+// Ruby annotation presentations, as named by Options.Ruby.
+const (
+	// RubyInline writes annotations in parentheses after their base text.
+	RubyInline = "inline"
+
+	// RubyHide leaves annotations out.
+	RubyHide = "hide"
+)
+
+// Parse takes in html content via an io.Reader and returns a document
+// containing only plain text, laid out according to the given options, along
+// with what was found in the content, such as its headings, links and ids.
+// Images are looked up among items. Headings at or above the split level mark
+// the start of a new section.
+func Parse(r io.Reader, items []epub.Item, opts Options) (Document, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return Document{}, err
+	}
+	doc, markers, err := parse(src, items, opts, nil)
+	// The numbers of ordered lists are only known once their items have
+	// been read, so a list numbered past what its indent fits is laid out
+	// again, indented by its widest number.
+	if markers != nil && err == nil {
+		doc, _, err = parse(src, items, opts, markers)
+	}
+
+	return doc, err
+}
+
+// parse lays out an html document as Parse does, indenting the ordered lists
+// in it by the widths of markers, by the order they start in. It returns the
+// width of the widest marker of each ordered list, or nil if each fit its
+// indent.
+func parse(src []byte, items []epub.Item, opts Options, markers []int) (Document, []int, error) {
+	tokenizer := html.NewTokenizer(skipBOM(bytes.NewReader(src)))
+	width := opts.Width
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	margin := opts.Margin
+	if max := (width - MinTextWidth) / 2; margin > max {
+		margin = max
+	}
+	if margin < 0 {
+		margin = 0
+	}
+	gutter := max(opts.Gutter, 0)
+	doc := Document{
+		Width:       width + gutter,
+		lmargin:     gutter + margin,
+		margin:      gutter + margin,
+		rmargin:     margin,
+		lineSpacing: opts.LineSpacing,
+		balanced:    opts.Wrap == WrapBalanced,
+		maxBreaks:   opts.MaxBlankLines,
+	}
+	if doc.maxBreaks <= 0 {
+		doc.maxBreaks = DefaultMaxBlankLines
+	}
+	var italicMarker string
+	doc.italic, italicMarker = italicStyle(opts.Italic)
+	doc.theme = Themes[0]
+	if opts.Theme != nil {
+		doc.theme = *opts.Theme
+	}
+	if doc.theme.Italic != termbox.ColorDefault {
+		doc.italic = doc.theme.Italic
+	}
+	if doc.noColor = opts.NoColor; doc.noColor {
+		mono, _ := LookupTheme("mono")
+		doc.theme = doc.theme.Monochrome()
+		doc.italic = monochrome(doc.italic, mono.Italic)
+	}
+	p := parser{
+		tokenizer:  tokenizer,
+		doc:        doc,
+		items:      items,
+		splitLevel: opts.Split,
+		images:     opts.Images,
+		imageOpts: ImageOptions{
+			Brightness: opts.Brightness,
+			Contrast:   opts.Contrast,
+			Mode:       opts.ImageMode,
+		},
+		highlight:    opts.Highlight,
+		italicMarker: italicMarker,
+		details:      opts.Details,
+
+		imageCaptionFormat: opts.ImageCaption,
+		thumbnailWidth:     opts.ThumbnailWidth,
+		thumbnailHint:      opts.ThumbnailHint,
+		expandDetails:      opts.ExpandDetails,
+		linting:            opts.Lint,
+
+		expandAbbreviations: opts.ExpandAbbreviations,
+		sectionBreaks:       opts.SectionBreaks,
+		markerIndents:       markers,
+	}
+	p.layout, _ = LookupLayout(opts.Layout)
+	if opts.NoColor {
+		p.imageOpts.Mode = ImageASCII
+	}
+	if p.imageCaptionFormat == "" {
+		p.imageCaptionFormat = DefaultImageCaption
+	}
+	if p.ruby = opts.Ruby; p.ruby != RubyHide {
+		p.ruby = RubyInline
+	}
+	err := p.parse()
+	if p.media != nil {
+		p.endMedia()
+	}
+	if p.link != nil {
+		p.endLink()
+	}
+	p.doc.balance()
+	p.doc.trim()
+	p.doc.endParagraphs()
+	p.resolveIDs()
+	if p.linting && err == nil && p.doc.Rows() == 0 && len(p.doc.Resources) == 0 {
+		p.doc.Problems = append(p.doc.Problems, Problem{Message: "document has no content"})
+	}
+	if !p.markersOverflowed {
+		p.markerWidths = nil
+	}
+	if err != nil {
+		return p.doc, p.markerWidths, err
+	}
+	return p.doc, p.markerWidths, nil
+}
+
+// byteOrderMark may precede the content of UTF-8 encoded files.
+var byteOrderMark = []byte("\ufeff")
+
+// skipBOM returns a reader that skips a leading byte order mark in r.
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(len(byteOrderMark)); bytes.Equal(b, byteOrderMark) {
+		br.Discard(len(byteOrderMark))
+	}
+
+	return br
+}
+
+// parse walks the html document read by the parser's tokenizer and renders
+// elements to its cell buffer document.
+func (p *parser) parse() (err error) {
+	for {
+		tokenType := p.tokenizer.Next()
+		offset := p.read
+		p.read += len(p.tokenizer.Raw())
+		p.raw = nil
+		if tokenType == html.StartTagToken {
+			p.raw = bytes.Clone(p.tokenizer.Raw())
+		}
+		token := p.tokenizer.Token()
+		if p.koboSpan(tokenType, token) {
+			continue
+		}
+		if p.linting {
+			p.lint(tokenType, token)
+		}
+		if tokenType != html.TextToken && !wordElements[token.DataAtom] {
+			p.flushText()
+		}
+		p.doc.offset = offset
+		buffered := p.text.Len() > 0
+		switch tokenType {
+		case html.ErrorToken:
+			err = p.tokenizer.Err()
+		case html.StartTagToken:
+			// Void elements (e.g. <br>) have no end tag to pop them.
+			if !voidElements[token.DataAtom] {
+				p.push(token)
+				p.startHidden(token)
+			}
+			fallthrough
+		case html.SelfClosingTagToken:
+			if !p.hidden() && !hasAttr(token, "hidden") {
+				p.handleStartTag(token)
+			} else if token.DataAtom == atom.Details {
+				p.skipDetails()
+			}
+		case html.TextToken:
+			if !p.hidden() {
+				p.handleText(token)
+			}
+		case html.EndTagToken:
+			if p.hidden() {
+				p.pop(token.DataAtom)
+				p.endHidden(token.DataAtom)
+				break
+			}
+			if token.DataAtom == atom.Rt && p.ruby == RubyInline && p.within(atom.Rt) {
+				p.text.WriteString(")")
+			}
+			if p.italicMarker != "" && p.italicDepth() == 1 &&
+				(token.DataAtom == atom.I || token.DataAtom == atom.Em) {
+				p.doc.style(p.tagStack, p.colors)
+				p.doc.appendSuffix(p.italicMarker)
+			}
+			if !p.pop(token.DataAtom) {
+				break
+			}
+			p.endHidden(token.DataAtom)
+			p.indent()
+			if token.DataAtom == atom.Center {
+				p.doc.startLine()
+				p.doc.centered = p.within(atom.Center)
+			}
+			if token.DataAtom == atom.Caption {
+				p.endCaption()
+			}
+			if p.media != nil && len(p.tagStack) < p.media.depth {
+				p.endMedia()
+			}
+			if p.tooltip != nil && len(p.tagStack) < p.tooltip.depth {
+				p.endTooltip()
+			}
+			if p.link != nil && len(p.tagStack) < p.link.depth {
+				p.endLink()
+			}
+			p.endDivisions()
+			p.endLists()
+			if p.pre != nil && len(p.tagStack) < p.pre.depth {
+				p.endPre()
+			}
+			if p.code != nil && len(p.tagStack) < p.code.depth {
+				p.doc.style(p.tagStack, p.colors)
+				p.doc.appendCode(p.code)
+				p.code = nil
+			}
+			if p.heading != nil && len(p.tagStack) < p.headingDepth {
+				p.heading.Title = strings.Join(strings.Fields(p.heading.Title), " ")
+				p.doc.Headings = append(p.doc.Headings, *p.heading)
+				p.heading = nil
+			}
+		}
+		if !buffered && p.text.Len() > 0 {
+			p.textOffset = offset
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// maxTagDepth is the deepest that elements are tracked in the tag stack.
+// Elements nested more deeply take the style of their ancestor at that depth.
+const maxTagDepth = 256
+
+// wordElements are the HTML elements that may occur within a word, and so do
+// not separate the text on either side of them.
+var wordElements = map[atom.Atom]bool{
+	atom.Wbr:  true,
+	atom.Ruby: true,
+	atom.Rb:   true,
+	atom.Rt:   true,
+	atom.Rtc:  true,
+	atom.Rp:   true,
+}
+
+// voidElements are the HTML elements that have no content and no end tag.
+var voidElements = map[atom.Atom]bool{
+	atom.Area:   true,
+	atom.Base:   true,
+	atom.Br:     true,
+	atom.Col:    true,
+	atom.Embed:  true,
+	atom.Hr:     true,
+	atom.Img:    true,
+	atom.Input:  true,
+	atom.Link:   true,
+	atom.Meta:   true,
+	atom.Param:  true,
+	atom.Source: true,
+	atom.Track:  true,
+	atom.Wbr:    true,
+}
+
+// push opens an element, unless it is nested too deeply.
+func (p *parser) push(token html.Token) {
+	if len(p.tagStack) >= maxTagDepth {
+		if p.overflowed == nil {
+			p.overflowed = map[atom.Atom]int{}
+		}
+		p.overflow = append(p.overflow, token.DataAtom)
+		p.overflowed[token.DataAtom]++
+		return
+	}
+	p.tagStack = append(p.tagStack, token.DataAtom)
+	p.transforms = append(p.transforms, p.textTransform(token))
+	p.colors = append(p.colors, fontColor(token)|p.headerCell(token))
+}
+
+// pop closes the innermost open element matching an end tag, along with any
+// elements left open within it. Elements nested too deeply are matched first,
+// since they are the innermost, so that their end tags do not close the
+// elements around them. Other end tags are ignored. It returns false if no
+// element in the tag stack was closed.
+func (p *parser) pop(tag atom.Atom) bool {
+	if p.overflowed[tag] > 0 {
+		for {
+			last := p.overflow[len(p.overflow)-1]
+			p.overflow = p.overflow[:len(p.overflow)-1]
+			p.overflowed[last]--
+			if last == tag {
+				return false
+			}
+		}
+	}
+	for i := len(p.tagStack) - 1; i >= 0; i-- {
+		if p.tagStack[i] == tag {
+			p.tagStack = p.tagStack[:i]
+			p.transforms = p.transforms[:i]
+			p.colors = p.colors[:i]
+			p.overflow, p.overflowed = nil, nil
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleText appends text elements to the parser buffer. It filters elements
+// that should not be displayed as text (e.g. style blocks).
+func (p *parser) handleText(token html.Token) {
+	// Style tags are read as stylesheets rather than displayed.
+	if len(p.tagStack) > 0 && p.tagStack[len(p.tagStack)-1] == atom.Style {
+		p.rules = append(p.rules, parseStylesheet(token.Data)...)
+		return
+	}
+	// Skip whitespace between the XML declaration, doctype, and root element.
+	if len(p.tagStack) == 0 && len(p.doc.Cells) == 0 && strings.TrimSpace(token.Data) == "" {
+		return
+	}
+	// Characters are composed where they can be (e.g. "e" and a combining
+	// acute accent into "é"), since marks of no width are left out.
+	data := norm.NFC.String(stripBidi(normalizeNewlines(token.Data)))
+	if p.code != nil {
+		p.code.text.WriteString(data)
+		return
+	}
+	if p.media != nil {
+		p.media.fallback.WriteString(data)
+		return
+	}
+	// Ruby parentheses are written by the parser, if at all, and readings
+	// are left out of headings' titles.
+	annotation := p.within(atom.Rt) || p.within(atom.Rtc)
+	if p.within(atom.Rp) || annotation && p.ruby == RubyHide {
+		return
+	}
+	text := p.transform(data)
+	if p.heading != nil && !annotation {
+		p.heading.Title += " " + text
+	}
+	if p.tooltip != nil {
+		p.tooltip.text.WriteString(text)
+	}
+	if p.link != nil {
+		p.link.text.WriteString(text)
+	}
+	p.text.WriteString(text)
+}
+
+// flushText appends buffered text to the parser buffer.
+func (p *parser) flushText() {
+	if p.text.Len() == 0 {
+		return
+	}
+	p.doc.style(p.tagStack, p.colors)
+	offset := p.doc.offset
+	p.doc.offset = p.textOffset
+	if p.pre != nil {
+		p.appendPre(p.text.String())
+	} else {
+		p.doc.appendText(p.text.String())
+	}
+	p.doc.offset = offset
+	p.text.Reset()
+}
+
+// handleStartTag appends text representations of non-text elements (e.g. image alt
+// tags) to the parser buffer.
+func (p *parser) handleStartTag(token html.Token) {
+	if p.media != nil {
+		p.handleMediaTag(token)
+		return
+	}
+	p.indent()
+	if p.breaksBefore(token) {
+		p.doc.breakPage()
+	}
+	if label, ok := pageBreak(token); ok {
+		p.doc.Pages = append(p.doc.Pages, Page{Row: p.doc.row, Label: label})
+	}
+	p.addID(token)
+	p.startDivision(token)
+	p.startTooltip(token)
+	p.startLink(token)
+
+	switch token.DataAtom {
+	case atom.Img:
+		// Small images (e.g. icons) are kept within the flow of the text.
+		alt := altText(token)
+		row := p.doc.row
+		if p.inlineImage(token) {
+			text := alt
+			if item, ok := p.item(tokenAttr(token, "src")); ok && text == "" && p.images {
+				opts := p.imageOpts
+				opts.Width = 1
+				text = strings.TrimSpace(imageToText(item, opts))
+			}
+			p.text.WriteString(text)
+			p.addResource(tokenAttr(token, "src"), row)
+			break
+		}
+
+		// Display alt text in place of images. Images without an alt attribute
+		// are still captioned if they have a title or label.
+		_, found := p.item(tokenAttr(token, "src"))
+		caption, ok := p.imageCaption(token, alt, p.images && found)
+		captioned := false
+		for _, a := range token.Attr {
+			switch atom.Lookup([]byte(a.Key)) {
+			case atom.Alt:
+				if ok {
+					p.doc.appendText(caption + "\n")
+				}
+				captioned = true
+			case atom.Src:
+				if !p.images {
+					continue
+				}
+				if item, ok := p.item(a.Val); ok {
+					opts := p.imageOpts
+					opts.Width = p.imageWidth()
+					p.appendImage(itemPicture(item, opts))
+				}
+			}
+		}
+		if !captioned && ok {
+			p.doc.appendText(caption + "\n")
+		}
+		p.addResource(tokenAttr(token, "src"), row)
+	case atom.Svg:
+		if token.Type == html.StartTagToken {
+			p.handleSVG(token, p.raw)
+		}
+	case atom.Math:
+		if token.Type != html.StartTagToken {
+			break
+		}
+
+		// The expression is read in full, including its closing tag.
+		p.pop(atom.Math)
+		n := readMath(p.tokenizer, token)
+		text := mathToText(n)
+		if n.attr("display") == "block" {
+			p.text.WriteString("\n" + text + "\n")
+		} else {
+			p.text.WriteString(" " + text + " ")
+		}
+	case atom.I, atom.Em:
+		// Only the outermost of nested italic elements is marked.
+		if p.italicMarker != "" && token.Type == html.StartTagToken && p.italicDepth() == 1 {
+			p.text.WriteString(p.italicMarker)
+		}
+	case atom.Link:
+		if hasWord(strings.ToLower(tokenAttr(token, "rel")), "stylesheet") {
+			p.addStylesheet(tokenAttr(token, "href"))
+		}
+	case atom.Br:
+		p.doc.breaks++
+	case atom.Wbr:
+		p.text.WriteRune(zeroWidthSpace)
+	case atom.Rt:
+		if p.ruby == RubyInline && token.Type == html.StartTagToken {
+			p.text.WriteString("(")
+		}
+	case atom.P:
+		if token.Type == html.StartTagToken && !p.atContainerStart() {
+			p.doc.startParagraph(p.layout)
+		}
+	case atom.Li, atom.Dt, atom.Dd, atom.Blockquote, atom.Tr, atom.Td, atom.Th:
+		if token.Type == html.StartTagToken {
+			p.startContainer(token)
+		}
+	case atom.Ul, atom.Ol:
+		p.startList(token)
+	case atom.Hr:
+		p.doc.col = 0
+		p.doc.appendText(strings.Repeat("-", p.doc.textWidth()))
+	case atom.Center:
+		p.doc.startLine()
+		p.doc.centered = true
+	case atom.Caption:
+		if token.Type == html.StartTagToken {
+			p.startCaption()
+		}
+	case atom.Audio, atom.Video:
+		p.startMedia(token)
+	case atom.Details:
+		p.startDetails(token)
+	case atom.Summary:
+		if p.within(atom.Details) {
+			p.startSummary()
+		}
+	case atom.Pre, atom.Code, atom.Tt:
+		if token.DataAtom == atom.Pre && p.pre == nil && token.Type == html.StartTagToken {
+			p.startPre()
+		}
+		if p.highlight && p.code == nil && token.Type == html.StartTagToken {
+			p.code = newCodeBlock(token, len(p.tagStack))
+		}
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := headingLevels[token.DataAtom]
+		if level <= p.splitLevel {
+			p.doc.addSection(p.doc.row, p.division())
+		}
+		if p.heading == nil && token.Type == html.StartTagToken {
+			p.heading = &Heading{Row: p.doc.row, Level: level}
+			p.headingDepth = len(p.tagStack)
+		}
+	}
+}
+
+// String returns the text of the document, one line per row. Trailing spaces
+// and trailing blank rows are removed, and attributes are ignored. The second
+// cells of wide characters are left out.
+func (c Document) String() string {
+	if c.Width <= 0 {
+		return ""
+	}
+
+	var lines []string
+	var row strings.Builder
+	for y := 0; y*c.Width < len(c.Cells); y++ {
+		row.Reset()
+		for x := 0; x < c.Width; x++ {
+			ch := ' '
+			if i := y*c.Width + x; i < len(c.Cells) && c.Cells[i].Ch != 0 {
+				ch = c.Cells[i].Ch
+			}
+			row.WriteRune(ch)
+			if runeWidth(ch) == 2 {
+				x++
+			}
+		}
+		lines = append(lines, strings.TrimRight(row.String(), " "))
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// breakPage starts a new line, separated from any preceding text by a blank
+// row, in place of a page break.
+func (c *Document) breakPage() {
+	c.breaks = 0
+	if len(c.Cells) == 0 {
+		return
+	}
+	if c.col > c.lmargin {
+		c.newline()
+	}
+	c.newline()
+}
+
+// startParagraph moves the cursor to where a new paragraph starts, according
+// to the layout.
+func (c *Document) startParagraph(l Layout) {
+	c.breaks = 0
+	if c.col > c.lmargin {
+		c.newline()
+	}
+	if len(c.Cells) > 0 {
+		c.row += l.ParagraphSpacing * (1 + c.lineSpacing)
+	}
+	c.col = c.lmargin + l.Indent
+	c.addParagraph(c.row)
+}
+
+// addSection records row as the start of a new section of the given type.
+// Consecutive headings and divisions on the same row belong to a single
+// section.
+func (c *Document) addSection(row int, typ string) {
+	if n := len(c.Sections); n > 0 && c.Sections[n-1].Row >= row {
+		return
+	}
+	c.Sections = append(c.Sections, Section{Row: row, Type: typ})
+}
+
+// ImageOptions controls how images are rendered.
+type ImageOptions struct {
+	// Width is the number of columns the image is rendered to.
+	Width int
+
+	// Mode is how the image is rendered: ImageASCII or ImageBlocks. Other
+	// values select ImageASCII. Images are always rendered as ASCII art
+	// without colors (see Options.NoColor).
+	Mode string
+
+	// Brightness and Contrast adjust the image's grayscale values before they
+	// are mapped to characters, or its color channels before they are mapped
+	// to colors. Both are percentages from -100 to 100, where
+	// zero leaves the image unchanged.
+	Brightness int
+	Contrast   int
+}
+
+// adjust applies the brightness and contrast options to a grayscale value.
+func (o ImageOptions) adjust(y uint8) uint8 {
+	v := (float64(y)-128)*float64(100+o.Contrast)/100 + 128
+	v += float64(o.Brightness) * 255 / 100
+	if v < 0 {
+		return 0
+	} else if v > 255 {
+		return 255
+	}
+
+	return uint8(v)
+}
+
+// inlineImageSize is the largest width and height, in pixels, of images that
+// are displayed inline with text rather than as blocks.
+const inlineImageSize = 48
+
+// item returns the manifest item referred to by href. Items are matched by
+// their full path where possible, and otherwise by the end of it, since hrefs
+// are relative to the document rather than to the manifest.
+func (p *parser) item(href string) (epub.Item, bool) {
+	for _, item := range p.items {
+		if item.HREF == href {
+			return item, true
+		}
+	}
+
+	rel := strings.TrimLeft(path.Clean(href), "./")
+	if rel == "" {
+		return epub.Item{}, false
+	}
+	for _, item := range p.items {
+		if strings.HasSuffix("/"+item.HREF, "/"+rel) {
+			return item, true
+		}
+	}
+
+	return epub.Item{}, false
+}
+
+// altText returns the text that describes an image: its alt text, or failing
+// that its title, or failing that its ARIA label.
+func altText(token html.Token) string {
+	for _, key := range []string{"alt", "title", "aria-label"} {
+		if text := strings.TrimSpace(tokenAttr(token, key)); text != "" {
+			return text
+		}
+	}
+
+	return ""
+}
+
+// captionPlaceholder matches the placeholders in an image caption format.
+var captionPlaceholder = regexp.MustCompile(`\{(alt|src|title)\}`)
+
+// imageCaption fills in the placeholders of the image caption format for an
+// image with the given alt text. It returns false if the caption should be
+// left out: when none of the placeholders it uses have a value, or, for
+// formats without placeholders, when the image is rendered.
+func (p *parser) imageCaption(token html.Token, alt string, rendered bool) (string, bool) {
+	fields := map[string]string{
+		"alt":   alt,
+		"src":   tokenAttr(token, "src"),
+		"title": strings.TrimSpace(tokenAttr(token, "title")),
+	}
+	used, filled := false, false
+	caption := captionPlaceholder.ReplaceAllStringFunc(p.imageCaptionFormat, func(s string) string {
+		val := fields[s[1:len(s)-1]]
+		used, filled = true, filled || val != ""
+		return val
+	})
+
+	return caption, filled || !used && !rendered
+}
+
+// inlineImage reports whether an image is small enough to be displayed
+// inline with text, such as an icon or symbol. Its size is taken from its
+// width and height attributes or inline style where given, and otherwise from
+// the image itself.
+func (p *parser) inlineImage(token html.Token) bool {
+	if height, _ := p.cssValue(token, "height"); emHeight(height) {
+		return true
+	}
+
+	w, wok := pixels(tokenAttr(token, "width"))
+	h, hok := pixels(tokenAttr(token, "height"))
+	if !wok && !hok {
+		item, ok := p.item(tokenAttr(token, "src"))
+		if !ok {
+			return false
+		}
+		r, err := item.Open()
+		if err != nil {
+			return false
+		}
+		defer r.Close()
+		cfg, _, err := image.DecodeConfig(r)
+		if err != nil {
+			return false
+		}
+		w, h = cfg.Width, cfg.Height
+	}
+
+	return w <= inlineImageSize && h <= inlineImageSize
+}
+
+// imageWidth returns the number of columns images are rendered to: the width
+// of the text, or the thumbnail width where it is narrower.
+func (p *parser) imageWidth() int {
+	if p.thumbnailWidth > 0 {
+		return min(p.thumbnailWidth, p.doc.textWidth())
+	}
+
+	return p.doc.textWidth()
+}
+
+// appendImage appends a rendered image. Thumbnails, which are narrower than
+// the text, are followed by the thumbnail hint.
+func (p *parser) appendImage(pic [][]termbox.Cell) {
+	p.doc.appendBlock(pic)
+	if len(pic) > 0 && p.thumbnailHint != "" && p.imageWidth() < p.doc.textWidth() {
+		p.doc.appendText(p.thumbnailHint + "\n")
+	}
+}
+
+// pixels parses a length in pixels, as given by an image's width or height
+// attribute (e.g. "32" or "32px").
+func pixels(s string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(s), "px"))
+	return n, err == nil
+}
+
+// emHeight reports whether a CSS height is at most a couple of lines of text
+// (e.g. "1em").
+func emHeight(height string) bool {
+	if !strings.HasSuffix(height, "em") {
+		return false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSuffix(height, "em"), 64)
+
+	return err == nil && n <= 2
+}
+
+// decodeImage reads and decodes the image in item.
+func decodeImage(item epub.Item) (image.Image, error) {
+	r, err := item.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	if isSVG(item) {
+		return decodeSVG(r)
+	}
+	img, _, err := image.Decode(r)
+
+	return img, err
+}
+
+// imageToText renders an image as ASCII art.
+func imageToText(item epub.Item, opts ImageOptions) string {
+	img, err := decodeImage(item)
+	if err != nil {
+		return ""
+	}
+
+	return RenderImage(img, opts)
+}
+
+// itemPicture renders an image as rows of cells, in the mode of opts.
+func itemPicture(item epub.Item, opts ImageOptions) [][]termbox.Cell {
+	img, err := decodeImage(item)
+	if err != nil {
+		return nil
+	}
+
+	return picture(img, opts)
+}
+
+// picture renders an image as rows of cells: in color when opts selects
+// ImageBlocks, and otherwise as ASCII art, whose cells take the colors of the
+// text.
+func picture(img image.Image, opts ImageOptions) [][]termbox.Cell {
+	if opts.Mode == ImageBlocks {
+		return renderBlocks(img, opts)
+	}
+
+	var rows [][]termbox.Cell
+	for _, line := range strings.SplitAfter(RenderImage(img, opts), "\n") {
+		if line == "" {
+			continue
+		}
+		var row []termbox.Cell
+		for _, r := range strings.TrimSuffix(line, "\n") {
+			row = append(row, termbox.Cell{Ch: r})
+		}
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// RenderImage renders an image as ASCII art. Images with no area, or that
+// would be rendered with no columns, produce an empty string.
+func RenderImage(img image.Image, opts ImageOptions) string {
+	w := opts.Width
+	bounds := img.Bounds()
+	if bounds.Dx() <= 0 || bounds.Dy() <= 0 || w <= 0 {
+		return ""
+	}
+
+	// Assume a character height to width ratio of 2:1. Very wide, short
+	// images (e.g. decorative rules) still get a single row.
+	h := (bounds.Dy() * w) / (bounds.Dx() * 2)
+	if h < 1 {
+		h = 1
+	}
+	img = resize.Resize(uint(w), uint(h), img, resize.Lanczos3)
+	bounds = img.Bounds()
+
+	charGradient := []rune("MND8OZ$7I?+=~:,..")
+	buf := new(bytes.Buffer)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y))
+			y := opts.adjust(c.(color.Gray).Y)
+			pos := (len(charGradient) - 1) * int(y) / 255
+			buf.WriteRune(charGradient[pos])
+		}
+		buf.WriteRune('\n')
+	}
+
+	return buf.String()
+}
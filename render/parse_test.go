@@ -0,0 +1,1599 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/taylorskalyo/goreader/epub"
+)
+
+const expFormat = "Expected: %v, but got: %v\n"
+
+func TestRenderImage(t *testing.T) {
+	testCases := []struct {
+		name          string
+		width, height int
+		w             int
+		expRows       int
+	}{
+		{"1x1", 1, 1, 80, 40},
+		{"1000x1", 1000, 1, 80, 1},
+		{"0x0", 0, 0, 80, 0},
+		{"1x0", 1, 0, 80, 0},
+		{"NoColumns", 10, 10, 0, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := image.NewGray(image.Rect(0, 0, tc.width, tc.height))
+			for i := range img.Pix {
+				img.Pix[i] = 0xff
+			}
+
+			text := RenderImage(img, ImageOptions{Width: tc.w})
+			rows := strings.Count(text, "\n")
+			if rows != tc.expRows {
+				t.Errorf(expFormat, tc.expRows, rows)
+			}
+
+			for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+				if n := len([]rune(line)); rows > 0 && n != tc.w {
+					t.Errorf(expFormat, tc.w, n)
+				}
+			}
+		})
+	}
+}
+
+func TestImageOptionsAdjust(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		brightness, contrast int
+		y, exp               uint8
+	}{
+		{"Unchanged", 0, 0, 77, 77},
+		{"Brighter", 10, 0, 100, 125},
+		{"ClampHigh", 100, 0, 200, 255},
+		{"ClampLow", -100, 0, 50, 0},
+		{"MoreContrast", 0, 100, 100, 72},
+		{"NoContrast", 0, -100, 10, 128},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := ImageOptions{Brightness: tc.brightness, Contrast: tc.contrast}
+			if y := opts.adjust(tc.y); y != tc.exp {
+				t.Errorf(expFormat, tc.exp, y)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	src := "<pre><code class=\"language-go\">\nfunc main() {\n\treturn\n}\n</code></pre>"
+	doc, err := Parse(strings.NewReader(src), nil, Options{Highlight: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		col, row int
+		expCh    rune
+		expFg    termbox.Attribute
+	}{
+		{0, 0, 'f', termbox.ColorBlue | termbox.AttrBold},
+		{5, 0, 'm', termbox.ColorYellow},
+		{4, 1, 'r', termbox.ColorBlue | termbox.AttrBold},
+		{0, 2, '}', termbox.ColorDefault},
+	}
+
+	for _, tc := range testCases {
+		cell := doc.Cells[tc.row*doc.Width+tc.col]
+		if cell.Ch != tc.expCh {
+			t.Errorf(expFormat, string(tc.expCh), string(cell.Ch))
+		}
+		if cell.Fg != tc.expFg {
+			t.Errorf(expFormat, tc.expFg, cell.Fg)
+		}
+	}
+}
+
+// rowText returns the text on a row of a cell buffer document, with trailing
+// spaces removed.
+func rowText(c Document, row int) string {
+	lines := strings.Split(c.String(), "\n")
+	if row >= len(lines) {
+		return ""
+	}
+
+	return lines[row]
+}
+
+func TestString(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Empty", "", ""},
+		{"Paragraphs", "<p>One two.</p>\n<p>Three.</p>", "  One two.\n  Three."},
+		{"Break", "<div>a<br/>b</div>", "a\nb"},
+		{"Wrap", "<div>" + strings.Repeat("word ", 20) + "</div>",
+			strings.TrimSpace(strings.Repeat("word ", 16)) + "\n" + strings.TrimSpace(strings.Repeat("word ", 4))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestWordBreak(t *testing.T) {
+	a := strings.Repeat("a", 50)
+	b := strings.Repeat("b", 50)
+	testCases := []struct {
+		name   string
+		src    string
+		expRow []string
+	}{
+		{"Fits", "<p>ab<wbr/>cd</p>", []string{"  abcd"}},
+		{"Break", "<div>" + a + "<wbr/>" + b + "</div>", []string{a, b}},
+		{"HTMLVoid", "<div>" + a + "<wbr>" + b + "</div>", []string{a, b}},
+		{"ZeroWidthSpace", "<div>" + a + "\u200b" + b + "</div>", []string{a, b}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for row, exp := range tc.expRow {
+				if text := rowText(doc, row); text != exp {
+					t.Errorf(expFormat, exp, text)
+				}
+			}
+		})
+	}
+}
+
+func TestItalic(t *testing.T) {
+	testCases := []struct {
+		name    string
+		italic  string
+		src     string
+		expText string
+		expFg   termbox.Attribute
+	}{
+		{"Default", "", "<i>a</i>", "a", termbox.AttrUnderline},
+		{"Color", "cyan", "<em>a</em>", "a", termbox.ColorCyan},
+		{"NestedBold", "cyan", "<b><i>a</i></b>", "a", termbox.ColorCyan | termbox.AttrBold},
+		{"InnerColor", "cyan", "<h1><i>a</i></h1>", "a", termbox.ColorCyan},
+		{"Marker", "/", "<div>an <i>odd</i> word</div>", "an /odd/ word", termbox.ColorDefault},
+		{"NestedMarker", "/", "<div><i>a <em>b</em> c</i></div>", "/a b c/", termbox.ColorDefault},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := strings.NewReader(tc.src)
+			doc, err := Parse(src, nil, Options{Italic: tc.italic})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := rowText(doc, 0); text != tc.expText {
+				t.Errorf(expFormat, tc.expText, text)
+			}
+			if fg := doc.Cells[strings.Index(tc.expText, "a")].Fg; fg != tc.expFg {
+				t.Errorf(expFormat, tc.expFg, fg)
+			}
+		})
+	}
+}
+
+func TestBOM(t *testing.T) {
+	f, err := os.Open("_test_files/bom.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := Parse(f, nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The document should be laid out as if it had no BOM or prolog.
+	src := "<html xmlns=\"http://www.w3.org/1999/xhtml\">\n<body>\n<p>It was a bright cold day in April.</p>\n</body>\n</html>\n"
+	exp, err := Parse(strings.NewReader(src), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.String() != exp.String() {
+		t.Errorf(expFormat, exp.String(), doc.String())
+	}
+	if !strings.Contains(doc.String(), "It was a bright cold day in April.") {
+		t.Errorf(expFormat, "It was a bright cold day in April.", doc.String())
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	b, err := os.ReadFile("_test_files/crlf.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := Parse(strings.NewReader(string(b)), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The document should be laid out as if its lines ended with line
+	// feeds, and its carriage return were one.
+	src := strings.NewReplacer("\r\n", "\n", "&#13;", "\n").Replace(string(b))
+	exp, err := Parse(strings.NewReader(src), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.String() != exp.String() {
+		t.Errorf(expFormat, exp.String(), doc.String())
+	}
+	if strings.ContainsRune(doc.String(), '\r') {
+		t.Errorf("Expected no carriage returns in %q", doc.String())
+	}
+}
+
+func TestLayout(t *testing.T) {
+	src := "<p>One.</p>\n<p>Two.</p><p>Three.</p>"
+	testCases := []struct {
+		layout string
+		exp    string
+	}{
+		{"", "  One.\n  Two.\n  Three."},
+		{"novel", "  One.\n  Two.\n  Three."},
+		{"article", "One.\n\nTwo.\n\nThree."},
+		{"compact", "One.\nTwo.\nThree."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.layout, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, Options{Layout: tc.layout})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestPresentationalTags(t *testing.T) {
+	t.Run("Center", func(t *testing.T) {
+		src := "<p>Left</p><center>THE END<br/>of the story told here</center><p>After</p>"
+		doc, err := Parse(strings.NewReader(src), nil, Options{Width: 20, Layout: "compact"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := "Left\n      THE END\n of the story told\n        here\nAfter"
+		if text := doc.String(); text != exp {
+			t.Errorf(expFormat, exp, text)
+		}
+	})
+
+	// The rows of a long centered block run past the cells first
+	// allocated.
+	t.Run("CenterLong", func(t *testing.T) {
+		src := "<center>" + strings.Repeat("<p>a</p>", 11) + "</center><p>after</p>"
+		doc, err := Parse(strings.NewReader(src), nil, Options{Width: 100, Layout: "compact"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := strings.Repeat(strings.Repeat(" ", 49)+"a\n", 11) + "after"
+		if text := doc.String(); text != exp {
+			t.Errorf(expFormat, exp, text)
+		}
+	})
+
+	testCases := []struct {
+		name  string
+		src   string
+		expFg termbox.Attribute
+	}{
+		{"Big", "<big>a</big>", termbox.AttrBold},
+		{"Small", "<small>a</small>", termbox.AttrDim},
+		{"FontName", `<font color="maroon">a</font>`, termbox.ColorRed},
+		{"FontHex", `<font color="#00f">a</font>`, termbox.ColorBlue},
+		{"FontNested", `<font color="green"><b><font color="teal">a</font></b></font>`, termbox.ColorCyan | termbox.AttrBold},
+		{"FontGray", `<font color="gray">a</font>`, termbox.ColorDefault},
+		{"FontInvalid", `<font color="bogus">a</font>`, termbox.ColorDefault},
+		{"Tt", "<tt>a</tt>", termbox.ColorDefault},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fg := doc.Cells[0].Fg; fg != tc.expFg {
+				t.Errorf(expFormat, tc.expFg, fg)
+			}
+		})
+	}
+}
+
+func TestKoboSpans(t *testing.T) {
+	src := `<p><span class="koboSpan" id="kobo.1.1">"Oh dear!</span><span class="koboSpan" id="kobo.1.2">"</span> said <span class="name">the <span class="koboSpan" id="kobo.1.3">Rabbit</span>.</span></p>`
+	doc, err := Parse(strings.NewReader(src), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `  "Oh dear!" said the Rabbit.`
+	if text := doc.String(); text != exp {
+		t.Errorf(expFormat, exp, text)
+	}
+}
+
+func TestNarrowMargins(t *testing.T) {
+	testCases := []struct {
+		name   string
+		width  int
+		margin int
+		gutter int
+		exp    string
+	}{
+		{"Fits", 20, 2, 0, "  ----------------\n    One two."},
+		{"TooWide", 20, 30, 0, "     ----------\n       One two."},
+		{"NarrowDocument", 8, 3, 0, "--------\n  One\ntwo."},
+		{"Gutter", 20, 2, 4, "      ----------------\n        One two."},
+		{"GutterTooWide", 20, 30, 4, "         ----------\n           One two."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader("<hr/><p>One two.</p>"), nil, Options{Width: tc.width, Margin: tc.margin, Gutter: tc.gutter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestContainers(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Items", "<ul><li>One</li><li>Two</li></ul>", "• One\n• Two"},
+		{"ItemParagraphs", "<ul><li><p>One</p></li><li><p>Two</p><p>More</p></li></ul>", "• One\n• Two\n    More"},
+		{"Definitions", "<dl><dt><p>Term</p></dt><dd><p>Meaning</p></dd></dl>", "Term\nMeaning"},
+		{"Blockquote", "<p>Before.</p><blockquote><p>Quoted.</p><p>Again.</p></blockquote><p>After.</p>", "  Before.\n      Quoted.\n      Again.\n  After."},
+		{"QuotedList", "<blockquote><p>Lines:</p><ul><li><p>one</p></li><li>two</li></ul></blockquote>", "      Lines:\n    • one\n    • two"},
+		{"TableCells", "<table><tr><td><p>a</p></td><td><p>b</p></td></tr><tr><th>c</th><td>d</td></tr></table>", "a b\nc d"},
+		{"TableCaption", "<p>Text</p><table><caption>Scores</caption><tr><th>Name</th><th>Score</th></tr><tr><td>Ann</td><td>3</td></tr></table>", "  Text\n                                     Scores\nName Score\nAnn 3"},
+		{"TableCaptionLate", strings.Repeat("<p>Text</p>", 38) + "<table><caption>Scores</caption><tr><td>Ann</td><td>3</td></tr></table>", strings.Repeat("  Text\n", 38) + "                                     Scores\nAnn 3"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	src := "<p>The quick brown fox jumps over the lazy dog and then <i>runs away</i> into the woods.</p><p>Short one here.</p>"
+	testCases := []struct {
+		wrap string
+		exp  string
+	}{
+		{"", "  The quick brown fox\njumps over the lazy dog\nand then /runs away/\ninto the woods.\n  Short one here."},
+		{WrapGreedy, "  The quick brown fox\njumps over the lazy dog\nand then /runs away/\ninto the woods.\n  Short one here."},
+		{WrapBalanced, "  The quick brown fox\njumps over the lazy\ndog and then /runs\naway/ into the woods.\n  Short one here."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.wrap, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, Options{Width: 24, Italic: "/", Wrap: tc.wrap})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestLongWords(t *testing.T) {
+	word := strings.Repeat("longword", 6)
+	testCases := []struct {
+		name string
+		wrap string
+		src  string
+		exp  string
+	}{
+		{"Inside", "", "<p>ab " + word + " cd</p>", "  ab longwordlongwor\ndlongwordlongwordlon\ngwordlongword cd"},
+		{"First", "", "<p>" + word + " cd</p>", "  longwordlongwordlo\nngwordlongwordlongwo\nrdlongword cd"},
+		{"Balanced", WrapBalanced, "<p>ab " + word + " cd</p>", "  ab longwordlongwor\ndlongwordlongwordlon\ngwordlongword cd"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Width: 20, Wrap: tc.wrap})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestWordSpacing(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Punctuation", "<div>The <b>WHO</b>, then</div>", "The WHO,\nthen"},
+		{"Adjacent", "<div><b>a</b><i>b</i> c</div>", "ab c"},
+		{"Spaced", "<div><b>a</b> <i>b</i></div>", "a b"},
+		{"LineFeed", "<div>aaaa bbbbb\ncc</div>", "aaaa bbbbb\ncc"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Width: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+			if doc.col > doc.Width {
+				t.Errorf(expFormat, doc.Width, doc.col)
+			}
+		})
+	}
+}
+
+func TestRuby(t *testing.T) {
+	src := "<p>Read <ruby>漢字<rp>(</rp><rt>かんじ</rt><rp>)</rp></ruby> and <ruby>東<rt>とう</rt>京<rt>きょう</rt></ruby>.</p>"
+	testCases := []struct {
+		ruby string
+		exp  string
+	}{
+		{"", "  Read 漢字(かんじ) and 東(とう)京(きょう)."},
+		{RubyInline, "  Read 漢字(かんじ) and 東(とう)京(きょう)."},
+		{RubyHide, "  Read 漢字 and 東京."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.ruby, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, Options{Ruby: tc.ruby})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestBalancedBreaks(t *testing.T) {
+	testCases := []struct {
+		name    string
+		lengths []int
+		first   int
+		width   int
+		n       int
+		exp     []int
+		ok      bool
+	}{
+		{"Even", []int{3, 3, 3, 3}, 7, 7, 2, []int{2}, true},
+		{"ShortLastLine", []int{4, 4, 4, 4, 1}, 14, 14, 2, []int{2}, true},
+		{"Indent", []int{2, 2, 2}, 3, 5, 2, []int{1}, true},
+		{"TooLong", []int{3, 3, 3}, 3, 3, 2, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			breaks, ok := balancedBreaks(tc.lengths, tc.first, tc.width, tc.n)
+			if ok != tc.ok || fmt.Sprint(breaks) != fmt.Sprint(tc.exp) {
+				t.Errorf(expFormat, tc.exp, breaks)
+			}
+		})
+	}
+}
+
+func TestPages(t *testing.T) {
+	src := `<p>One.<span epub:type="pagebreak" id="p2" title="2"/> Two.</p>
+<div role="doc-pagebreak" aria-label="[3]"></div>
+<span epub:type="pagebreak"></span>`
+	doc, err := Parse(strings.NewReader(src), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Page{{Row: 0, Label: "2"}, {Row: 1, Label: "3"}}
+	if len(doc.Pages) != len(exp) {
+		t.Fatalf(expFormat, exp, doc.Pages)
+	}
+	for i := range exp {
+		if doc.Pages[i] != exp[i] {
+			t.Errorf(expFormat, exp[i], doc.Pages[i])
+		}
+	}
+
+}
+
+func TestBreakBefore(t *testing.T) {
+	src := `<p>One.</p><p style="color: red; page-break-before: always">Two.</p><p style="break-before: auto">Three.</p>`
+	doc, err := Parse(strings.NewReader(src), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp, text := "  One.\n\n  Two.\n  Three.", doc.String(); text != exp {
+		t.Errorf(expFormat, exp, text)
+	}
+}
+
+func TestInlineImage(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Attributes", `<p>Press <img src="ok.png" alt="[OK]" width="16" height="16"/> to go.</p>`, "  Press [OK] to go."},
+		{"Style", `<p>Press <img src="ok.png" alt="[OK]" style="height: 1em"/> to go.</p>`, "  Press [OK] to go."},
+		{"Block", `<p>See <img src="map.png" alt="A map" width="600"/></p>`, "  See Alt text: A map"},
+		{"Title", `<p>See <img src="map.png" alt="" title="A map" width="600"/></p>`, "  See Alt text: A map"},
+		{"Label", `<p>See <img src="map.png" aria-label="A map" width="600"/></p>`, "  See Alt text: A map"},
+		{"InlineTitle", `<p>Press <img src="ok.png" title="[OK]" width="16" height="16"/> to go.</p>`, "  Press [OK] to go."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Images: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestDeepNesting(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{
+			"Closed",
+			strings.Repeat("<span>", 10000) + "deep" + strings.Repeat("</span>", 10000) + " <b>bold</b>",
+			"deep bold",
+		},
+		{
+			"Unclosed",
+			"<div>" + strings.Repeat("<span>a ", 100000) + "</div><b>bold</b>",
+			strings.TrimSpace(strings.Repeat("a ", 4)),
+		},
+		{"StrayEndTags", "</b></div><b>bold</b></p>", "bold"},
+		{
+			"OverflowInside",
+			strings.Repeat("<span>", 250) + "<b>" + strings.Repeat("<span>", 10) + "x" + strings.Repeat("</span>", 10) + "bold</b>",
+			"xbold",
+		},
+		{"VoidElements", strings.Repeat("<br>", 5) + "<b>bold</b>", "bold"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := strings.TrimSpace(doc.String())
+			if !strings.HasPrefix(text, tc.exp) {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+
+			// Text after the nested elements is styled only by its own.
+			i := len(doc.Cells) - 1
+			for i >= 0 && doc.Cells[i].Ch != 'd' {
+				i--
+			}
+			if i < 0 {
+				t.Fatalf(expFormat, "bold", text)
+			}
+			if fg := doc.Cells[i].Fg; fg != termbox.AttrBold {
+				t.Errorf(expFormat, termbox.AttrBold, fg)
+			}
+		})
+	}
+}
+
+func TestDetails(t *testing.T) {
+	src := `<p>Before.</p><details><summary>Notes</summary><p>Hidden.</p><details open=""><summary>Inner</summary><p>Deep.</p></details></details><p>After.</p><details open=""><summary>Open</summary><p>Shown.</p></details>`
+	testCases := []struct {
+		name string
+		opts Options
+		exp  string
+	}{
+		{"Collapsed", Options{}, "  Before.\n▸ Notes\n  After.\n▾ Open\n  Shown."},
+		{"Expanded", Options{ExpandDetails: true}, "  Before.\n▾ Notes\n  Hidden.\n▾ Inner\n  Deep.\n  After.\n▾ Open\n  Shown."},
+		{"Override", Options{Details: map[int]bool{0: true, 2: false}}, "  Before.\n▾ Notes\n  Hidden.\n▾ Inner\n  Deep.\n  After.\n▸ Open"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+			if len(doc.Details) != 3 {
+				t.Errorf(expFormat, 3, len(doc.Details))
+			}
+		})
+	}
+}
+
+func TestHidden(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Nav", `<h1>Contents</h1><nav hidden=""><ol><li><a href="c1.xhtml">One</a><ol><li>Two</li></ol></li></ol></nav><p>After.</p>`, "Contents\n  After."},
+		{"Inline", `<p>Shown <span hidden="">hidden </span>text.</p>`, "  Shown text."},
+		{"Void", `<p>a <br hidden=""/>b</p>`, "  a b"},
+		{"Details", `<div hidden=""><details><summary>A</summary></details></div><details open=""><summary>B</summary></details>`, "▾ B"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestLineBreaks(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		max  int
+		exp  string
+	}{
+		{"Single", "<div>a<br/>b</div>", 0, "a\nb"},
+		{"Double", "<div>a<br/><br/>b</div>", 0, "a\n\nb"},
+		{"Spaced", "<div>a <br/>\n <br/> b</div>", 0, "a\n\nb"},
+		{"Capped", "<div>a" + strings.Repeat("<br/>", 6) + "b</div>", 0, "a\n\n\nb"},
+		{"CustomCap", "<div>a<br/><br/><br/>b</div>", 1, "a\n\nb"},
+		{"Leading", "<br/><br/><div>a</div>", 0, "a"},
+		{"Trailing", "<div>a<br/><br/></div><p>b</p>", 0, "a\n  b"},
+		{"Verse", "<p>one<br/>two<br/><br/>three</p>", 0, "  one\ntwo\n\nthree"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{MaxBlankLines: tc.max})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestRows(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  int
+	}{
+		{"Empty", "", 0},
+		{"OneLine", "<p>One line.</p>", 1},
+		{"Paragraphs", "<p>One.</p><p>Two.</p><p>Three.</p>", 5},
+		{"TrailingBreaks", "<div>a<br/><br/><br/></div>", 1},
+		{"TrailingBlankLines", `<p>a</p><pre><code class="language-go">x := 1` + "\n\n   \n  </code></pre>", 2},
+		{"Blank", `<pre><code class="language-go">  ` + "\n  </code></pre>", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Layout: "article", Highlight: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rows := doc.Rows(); rows != tc.exp {
+				t.Errorf(expFormat, tc.exp, rows)
+			}
+		})
+	}
+}
+
+func TestImageCaption(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+		src    string
+		exp    string
+	}{
+		{"Default", "", `<img src="map.png" alt="A map"/>`, "Alt text: A map"},
+		{"DefaultNoAlt", "", `<img src="map.png"/><p>After</p>`, "  After"},
+		{"Figure", "[Figure: {alt}]", `<img src="map.png" alt="A map"/>`, "[Figure: A map]"},
+		{"Source", "({src})", `<img src="map.png"/>`, "(map.png)"},
+		{"Title", "{alt} - {title}", `<img src="map.png" alt="A map" title="Wonderland"/>`, "A map - Wonderland"},
+		{"Glyph", "▣", `<img src="map.png"/>`, "▣"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{ImageCaption: tc.format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestAnchors(t *testing.T) {
+	src := "<h1>Title</h1><p>one two three four five six seven eight nine ten</p><p>eleven twelve thirteen fourteen fifteen</p>"
+	narrow, err := Parse(strings.NewReader(src), nil, Options{Width: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wide, err := Parse(strings.NewReader(src), nil, Options{Width: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	narrowLines := strings.Split(narrow.String(), "\n")
+	wideLines := strings.Split(wide.String(), "\n")
+	for row, line := range narrowLines {
+		t.Run(line, func(t *testing.T) {
+			offset, ok := narrow.Offset(row)
+			if !ok {
+				t.Fatalf(expFormat, true, ok)
+			}
+			word := strings.Fields(line)[0]
+			if got := wideLines[wide.Row(offset)]; !strings.Contains(got, word) {
+				t.Errorf(expFormat, word, got)
+			}
+		})
+	}
+
+	if _, ok := narrow.Offset(len(narrowLines)); ok {
+		t.Errorf(expFormat, false, ok)
+	}
+}
+
+func TestMedia(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Source", `<p>Listen:</p><audio controls="" src="audio/chapter1.mp3">Not supported.</audio><p>After</p>`, "  Listen:\n[audio: chapter1.mp3]\n  After"},
+		{"SourceElement", `<video controls=""><source src="intro.webm" type="video/webm"/><source src="intro.mp4"/></video>`, "[video: intro.webm]"},
+		{"Fallback", `<video><p>A <b>short</b> film.</p></video>`, "[video: A short film.]"},
+		{"Empty", `<audio/><p>After</p>`, "[audio]\n  After"},
+		{"Unclosed", `<p>Before</p><audio src="a.mp3">`, "  Before\n[audio: a.mp3]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestInlineSVG(t *testing.T) {
+	square := `<p>Before</p><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10"><title>A square</title>` +
+		`<rect x="0" y="0" width="10" height="10" fill="black"/><text x="1" y="5">12 34</text></svg><p>After</p>`
+	cover := `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 600 800">` +
+		`<image width="600" height="800" xlink:href="../images/cover.jpg"/></svg><p>After</p>`
+	testCases := []struct {
+		name   string
+		src    string
+		images bool
+		exp    string
+	}{
+		{"Caption", square, false, "  Before\nAlt text: A square\n  After"},
+		{"Rendered", square, true, "  Before\nAlt text: A square\n" + strings.Repeat(strings.Repeat("M", 20)+"\n", 10) + "  After"},
+		{"MissingImage", cover, true, "  After"},
+		{"Nested", `<svg><g><svg><path d="M 0 0 L 1 1"/></svg><text>5 6</text></g></svg><p>After</p>`, false, "  After"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Width: 20, Images: tc.images})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestTooltips(t *testing.T) {
+	src := `<p>The <abbr title="World Health Organization">WHO</abbr> was founded <time datetime="1948-04-07">in 1948</time></p>` +
+		`<p>The <abbr title="World Health Organization">WHO</abbr> and <abbr title="USA">USA</abbr> <span title=" A  note ">agree</span><span title="empty"></span></p>`
+	testCases := []struct {
+		name string
+		opts Options
+		exp  string
+	}{
+		{"Plain", Options{}, "  The WHO was founded in 1948\n  The WHO and USA agree"},
+		{"Expanded", Options{ExpandAbbreviations: true}, "  The WHO (World Health Organization) was founded in 1948\n  The WHO and USA agree"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+
+	doc, err := Parse(strings.NewReader(src), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []Tooltip{
+		{Row: 0, End: 0, Text: "WHO", Title: "World Health Organization"},
+		{Row: 0, End: 0, Text: "in 1948", Title: "1948-04-07"},
+		{Row: 1, End: 1, Text: "WHO", Title: "World Health Organization"},
+		{Row: 1, End: 1, Text: "USA", Title: "USA"},
+		{Row: 1, End: 1, Text: "agree", Title: "A note"},
+	}
+	if len(doc.Tooltips) != len(exp) {
+		t.Fatalf(expFormat, exp, doc.Tooltips)
+	}
+	for i := range exp {
+		if doc.Tooltips[i] != exp[i] {
+			t.Errorf(expFormat, exp[i], doc.Tooltips[i])
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	items := []epub.Item{{HREF: "images/map.png"}}
+	testCases := []struct {
+		name string
+		src  string
+		exp  []string
+	}{
+		{"Clean", `<p>One</p><p>Two<br/>three</p><ul><li>a<li>b</ul>`, nil},
+		{"MissingImage", "<p>One</p>\n<img src=\"gone.png\" alt=\"\"/>", []string{"line 2: image gone.png not found in the manifest"}},
+		{"UnreadableImage", `<img src="../images/map.png" alt="A map"/>`, []string{"line 1: image ../images/map.png cannot be opened: epub: manifest references non-existent item: images/map.png"}},
+		{"NoSource", `<p>One <img alt="x"/></p>`, []string{"line 1: image has no source"}},
+		{"Unclosed", "<div>\n<p><b>One</p>\n<i>Two</div>", []string{"line 2: <b> is not closed before </p>", "line 3: <i> is not closed before </div>"}},
+		{"AtEnd", "<section><p>One", []string{"line 1: <section> is not closed at the end of the document"}},
+		{"Stray", "<p>One</span></p><br></br>", []string{"line 1: </span> does not close an open element"}},
+		{"XMLEncoding", `<?xml version="1.0" encoding="ISO-8859-1"?><p>One</p>`, []string{`line 1: unsupported encoding "ISO-8859-1"`}},
+		{"MetaCharset", `<head><meta charset="utf-8"/><meta http-equiv="Content-Type" content="text/html; charset=windows-1252"/></head><p>One</p>`, []string{`line 1: unsupported encoding "windows-1252"`}},
+		{"InvalidText", "<p>caf\xe9</p><p>na\xefve</p>", []string{"line 1: text is not valid UTF-8"}},
+		{"Empty", "<html><body>\n</body></html>", []string{"document has no content"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), items, Options{Lint: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, pr := range doc.Problems {
+				got = append(got, pr.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.exp, "\n") {
+				t.Errorf(expFormat, tc.exp, got)
+			}
+		})
+	}
+
+	doc, _ := Parse(strings.NewReader(`<p><b>One</p><img src="gone.png"/>`), nil, Options{})
+	if len(doc.Problems) != 0 {
+		t.Errorf(expFormat, 0, len(doc.Problems))
+	}
+}
+
+func TestResources(t *testing.T) {
+	items := []epub.Item{{HREF: "images/map.png"}, {HREF: "audio/a.mp3"}}
+	src := `<p>One</p><img src="images/map.png" alt="A map"/><p>Two</p><audio src="audio/a.mp3"/><img src="missing.png" alt="Gone"/>`
+	doc, err := Parse(strings.NewReader(src), items, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{"images/map.png 0", "audio/a.mp3 2"}
+	var got []string
+	for _, r := range doc.Resources {
+		got = append(got, fmt.Sprintf("%s %d", r.Item.HREF, r.Row))
+	}
+	if strings.Join(got, ",") != strings.Join(exp, ",") {
+		t.Errorf(expFormat, exp, got)
+	}
+}
+
+func TestParseAttribute(t *testing.T) {
+	testCases := []struct {
+		s      string
+		exp    termbox.Attribute
+		expErr bool
+	}{
+		{"", termbox.ColorDefault, false},
+		{"cyan", termbox.ColorCyan, false},
+		{"Yellow bold underline", termbox.ColorYellow | termbox.AttrBold | termbox.AttrUnderline, false},
+		{"#ff1010", termbox.ColorRed, false},
+		{"#000", termbox.ColorBlack, false},
+		{"reverse", termbox.AttrReverse, false},
+		{"orange", 0, true},
+		{"red blue", 0, true},
+		{"#12345", 0, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.s, func(t *testing.T) {
+			attr, err := ParseAttribute(tc.s)
+			if (err != nil) != tc.expErr {
+				t.Fatalf(expFormat, tc.expErr, err)
+			}
+			if attr != tc.exp {
+				t.Errorf(expFormat, tc.exp, attr)
+			}
+		})
+	}
+}
+
+func TestTheme(t *testing.T) {
+	src := "<h1>Title</h1><p>Body <b>bold</b> <i>italic</i> <a href=\"#x\">link</a> <mark>marked</mark></p>"
+	mono, _ := LookupTheme("mono")
+	custom := Theme{Body: termbox.ColorWhite, Bold: termbox.ColorRed}
+	testCases := []struct {
+		name  string
+		theme *Theme
+		exp   map[string]termbox.Attribute
+	}{
+		{"Default", nil, map[string]termbox.Attribute{
+			"Title":  termbox.ColorMagenta,
+			"Body":   termbox.ColorDefault,
+			"bold":   termbox.AttrBold,
+			"italic": termbox.AttrUnderline,
+			"link":   termbox.ColorDefault,
+			"marked": termbox.ColorDefault,
+		}},
+		{"Mono", &mono, map[string]termbox.Attribute{
+			"Title":  termbox.AttrBold | termbox.AttrUnderline,
+			"bold":   termbox.AttrBold,
+			"link":   termbox.AttrUnderline,
+			"marked": termbox.AttrReverse,
+		}},
+		{"Custom", &custom, map[string]termbox.Attribute{
+			"Title": termbox.ColorWhite,
+			"Body":  termbox.ColorWhite,
+			"bold":  termbox.ColorRed,
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, Options{Theme: tc.theme})
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := doc.String()
+			for word, exp := range tc.exp {
+				i := strings.Index(text, word)
+				row := strings.Count(text[:i], "\n")
+				col := len([]rune(text[strings.LastIndex(text[:i], "\n")+1 : i]))
+				if fg := doc.Cells[row*doc.Width+col].Fg; fg != exp {
+					t.Errorf("%s: "+expFormat, word, exp, fg)
+				}
+			}
+		})
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	testCases := []struct {
+		name string
+		fg   termbox.Attribute
+		attr termbox.Attribute
+		exp  termbox.Attribute
+	}{
+		{"Attribute", termbox.ColorBlue | termbox.AttrBold, termbox.AttrReverse, termbox.ColorBlue | termbox.AttrBold | termbox.AttrReverse},
+		{"Color", termbox.ColorBlue | termbox.AttrUnderline, termbox.ColorYellow | termbox.AttrReverse, termbox.ColorYellow | termbox.AttrUnderline | termbox.AttrReverse},
+		{"None", termbox.ColorRed, termbox.ColorDefault, termbox.ColorRed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if attr := Overlay(tc.fg, tc.attr); attr != tc.exp {
+				t.Errorf(expFormat, tc.exp, attr)
+			}
+		})
+	}
+
+	// Highlights keep their text attributes without colors.
+	mono := Themes[0].Monochrome()
+	if exp := termbox.AttrReverse | termbox.AttrBold; mono.SearchCurrent != exp {
+		t.Errorf(expFormat, exp, mono.SearchCurrent)
+	}
+	if mono.VisitedLink != termbox.AttrDim {
+		t.Errorf(expFormat, termbox.AttrDim, mono.VisitedLink)
+	}
+}
+
+func TestScanWords(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    string
+		atEOF   bool
+		advance int
+		token   string
+	}{
+		{"Word", "one two", false, 4, "one"},
+		{"LeadingSpaces", "  one two", false, 6, "one"},
+		{"MoreData", "one", false, 0, ""},
+		{"AtEOF", "one", true, 3, "one"},
+		{"Spaces", "   ", false, 3, ""},
+		{"Invalid", "caf\xe9 au", false, 5, "caf\xe9"},
+		{"LoneContinuation", "\x80\x80 b", false, 3, "\x80\x80"},
+		{"Truncated", "ab\xe2\x82", false, 0, ""},
+		{"TruncatedAtEOF", "ab\xe2\x82", true, 4, "ab\xe2\x82"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			advance, token, err := scanWords([]byte(tc.data), tc.atEOF)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if advance != tc.advance {
+				t.Errorf(expFormat, tc.advance, advance)
+			}
+			if string(token) != tc.token {
+				t.Errorf(expFormat, tc.token, string(token))
+			}
+		})
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	f, err := os.Open("_test_files/invalid_utf8.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := Parse(f, nil, Options{Width: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each invalid byte is replaced, and the text around it laid out as
+	// usual.
+	for _, exp := range []string{"  caf� au lait", "  stray �� bytes", "  overlong �� slash", "  ��", "  truncated ��"} {
+		if !slices.Contains(strings.Split(doc.String(), "\n"), exp) {
+			t.Errorf(expFormat, exp, doc.String())
+		}
+	}
+}
+
+func TestTableHeaders(t *testing.T) {
+	src := `<table><tr><th scope="col">Name</th><th>Score</th></tr><tr><th scope="row">Ann</th><td>3</td></tr><tr><td scope="row">Bob</td><td>4</td></tr></table>`
+	doc, err := Parse(strings.NewReader(src), nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		row, col int
+		exp      termbox.Attribute
+	}{
+		{0, 0, termbox.AttrBold},
+		{0, 5, termbox.AttrBold},
+		{1, 0, termbox.AttrBold},
+		{1, 4, termbox.ColorDefault},
+		{2, 0, termbox.AttrBold},
+		{2, 4, termbox.ColorDefault},
+	}
+	for _, tc := range testCases {
+		if fg := doc.Cells[tc.row*doc.Width+tc.col].Fg; fg != tc.exp {
+			t.Errorf("row %d, column %d: "+expFormat, tc.row, tc.col, tc.exp, fg)
+		}
+	}
+}
+
+func TestBidiControls(t *testing.T) {
+	testCases := []struct {
+		name  string
+		src   string
+		width int
+		exp   string
+	}{
+		{"Marks", "<div>left\u200e, right\u200f.</div>", 20, "left, right."},
+		{"Isolate", "<div>see \u2067שלום\u2069 here</div>", 20, "see שלום here"},
+		{"Override", "<div>\u202eabc\u202c def</div>", 20, "abc def"},
+		{"Wrap", "<div>aaaa\u200e\u200e\u200e bbbbb</div>", 10, "aaaa bbbbb"},
+		{"Alt", "<div><img alt=\"\u2066map\u2069\"/></div>", 20, "Alt text: map"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Width: tc.width})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestLinks(t *testing.T) {
+	src := `<h1 id="top">Title</h1><p id="first">One <a href="#top">back to <b>top</b></a></p>` +
+		`<p>Two <a href=" notes.xhtml#n1 ">a very long link that wraps</a><a href="empty.xhtml"></a></p><p><span id="top">Three</span></p>` +
+		`<p><a name="old">Four</a> <a href="https://example.com/">web</a> <a href="../text/My%20Notes.xhtml">x</a></p>`
+	items := []epub.Item{{HREF: "text/notes.xhtml"}, {HREF: "text/My Notes.xhtml"}}
+	doc, err := Parse(strings.NewReader(src), items, Options{Width: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []Link{
+		{Row: 1, Col: 6, End: 1, EndCol: 17, Text: "back to top", Href: "#top", Fragment: "top"},
+		{Row: 2, Col: 6, End: 3, EndCol: 15, Text: "a very long link that wraps", Href: "notes.xhtml#n1", Item: "text/notes.xhtml", Fragment: "n1"},
+		{Row: 5, Col: 7, End: 5, EndCol: 10, Text: "web", Href: "https://example.com/"},
+		{Row: 5, Col: 11, End: 5, EndCol: 12, Text: "x", Href: "../text/My%20Notes.xhtml", Item: "text/My Notes.xhtml"},
+	}
+	if len(doc.Links) != len(exp) {
+		t.Fatalf(expFormat, exp, doc.Links)
+	}
+	for i := range exp {
+		if doc.Links[i] != exp[i] {
+			t.Errorf(expFormat, exp[i], doc.Links[i])
+		}
+	}
+
+	// The first element with an id is on the row its text starts on. Anchors
+	// may be named instead.
+	expIDs := map[string]int{"top": 0, "first": 1, "old": 5}
+	if !maps.Equal(doc.IDs, expIDs) {
+		t.Errorf(expFormat, expIDs, doc.IDs)
+	}
+}
+
+func TestParagraphs(t *testing.T) {
+	src := `<h1>Title</h1><p>One two three four five six</p><p>Line<br/>break</p>` +
+		`<ul><li><p>Item</p></li></ul><p></p><p>End</p>`
+	testCases := []struct {
+		name string
+		opts Options
+		exp  []Paragraph
+	}{
+		{"Novel", Options{Width: 18}, []Paragraph{{0, 0}, {1, 2}, {3, 4}, {5, 5}, {7, 7}}},
+		{"Article", Options{Width: 18, Layout: "article"}, []Paragraph{{0, 0}, {2, 3}, {5, 6}, {7, 7}, {10, 10}}},
+		{"LineSpacing", Options{Width: 18, LineSpacing: 1}, []Paragraph{{0, 0}, {2, 4}, {6, 8}, {10, 10}, {14, 14}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(doc.Paragraphs, tc.exp) {
+				t.Errorf(expFormat, tc.exp, doc.Paragraphs)
+			}
+		})
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	src := `<h1>T</h1><p><font color="red">r</font> <i>i</i> <b>b</b> <a href="#x">l</a></p>`
+	mono, _ := LookupTheme("mono")
+	testCases := []struct {
+		name  string
+		opts  Options
+		attrs map[string]termbox.Attribute
+	}{
+		{"Color", Options{Italic: "yellow"}, map[string]termbox.Attribute{
+			"T": termbox.ColorMagenta, "r": termbox.ColorRed, "i": termbox.ColorYellow, "b": termbox.AttrBold, "l": termbox.ColorDefault,
+		}},
+		{"NoColor", Options{Italic: "yellow", NoColor: true}, map[string]termbox.Attribute{
+			"T": mono.Headings[0], "r": termbox.ColorDefault, "i": mono.Italic, "b": termbox.AttrBold, "l": termbox.ColorDefault,
+		}},
+		{"NoColorAttribute", Options{Italic: "bold", NoColor: true}, map[string]termbox.Attribute{
+			"i": termbox.AttrBold,
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for ch, exp := range tc.attrs {
+				i := slices.IndexFunc(doc.Cells, func(c termbox.Cell) bool { return c.Ch == rune(ch[0]) })
+				if i < 0 {
+					t.Fatalf("%q not found", ch)
+				}
+				if fg := doc.Cells[i].Fg; fg != exp {
+					t.Errorf(expFormat, exp, fg)
+				}
+			}
+		})
+	}
+}
+
+func TestIndent(t *testing.T) {
+	f, err := os.Open("_test_files/nested_blocks.xhtml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	doc, err := Parse(f, nil, Options{Width: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each list and quotation indents its content further, however they are
+	// nested.
+	exp := []string{
+		"  Before.",
+		"• One",
+		"• Two",
+		"        Quoted.",
+		"      1. Inner",
+		"      2. List",
+		"         • Deepest",
+		"        Quoted again.",
+		"• Three",
+		"          Twice quoted.",
+		"      Once.",
+		"  After.",
+	}
+	var lines []string
+	for _, line := range strings.Split(doc.String(), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if !slices.Equal(lines, exp) {
+		t.Errorf(expFormat, exp, lines)
+	}
+
+	// Indents leave at least MinTextWidth columns for text.
+	src := strings.Repeat("<blockquote>", 20) + "<p>Deep</p>"
+	doc, err = Parse(strings.NewReader(src), nil, Options{Width: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := strings.Repeat(" ", 30-MinTextWidth+2) + "Deep"; doc.String() != exp {
+		t.Errorf(expFormat, exp, doc.String())
+	}
+}
+
+func TestDivisions(t *testing.T) {
+	src := `<body epub:type="bodymatter chapter"><h1>One</h1><p>Text</p>` +
+		`<section epub:type="part"><h1>Two</h1><p>More</p></section>` +
+		`<aside epub:type="footnotes"><p>Note</p></aside></body>`
+	testCases := []struct {
+		name     string
+		opts     Options
+		sections []Section
+		text     string
+	}{
+		{"Plain", Options{Width: 20}, nil, "One\n  Text\nTwo\n  More\n  Note"},
+		{"Split", Options{Width: 20, Split: 1}, []Section{{0, "chapter"}, {2, "part"}, {4, "footnotes"}}, "One\n  Text\nTwo\n  More\n  Note"},
+		{"SectionBreaks", Options{Width: 20, Split: 1, SectionBreaks: true}, []Section{{0, "chapter"}, {5, "part"}, {8, "footnotes"}},
+			"One\n  Text\n\n--------------------\n\nTwo\n  More\n\n  Note"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(src), nil, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Type != "chapter" {
+				t.Errorf(expFormat, "chapter", doc.Type)
+			}
+			if !slices.Equal(doc.Sections, tc.sections) {
+				t.Errorf(expFormat, tc.sections, doc.Sections)
+			}
+			if s := doc.String(); s != tc.text {
+				t.Errorf(expFormat, tc.text, s)
+			}
+			if n := len(doc.Sections); n > 0 && !doc.Sections[n-1].Notes() {
+				t.Errorf("Expected the last section to hold notes")
+			}
+		})
+	}
+}
+
+func TestThumbnails(t *testing.T) {
+	src := `<p>Before</p><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">` +
+		`<rect x="0" y="0" width="10" height="10" fill="black"/></svg><p>After</p>`
+	testCases := []struct {
+		name  string
+		width int
+		exp   string
+	}{
+		{"Full", 0, "  Before\n" + strings.Repeat(strings.Repeat("M", 20)+"\n", 10) + "  After"},
+		{"Thumbnail", 8, "  Before\n" + strings.Repeat(strings.Repeat("M", 8)+"\n", 4) + "[view]\n  After"},
+		{"WiderThanText", 40, "  Before\n" + strings.Repeat(strings.Repeat("M", 20)+"\n", 10) + "  After"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := Options{Width: 20, Images: true, ThumbnailWidth: tc.width, ThumbnailHint: "[view]"}
+			doc, err := Parse(strings.NewReader(src), nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestLists(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Ordered", "<ol><li>One</li><li>Two</li></ol>", "1. One\n2. Two"},
+		{"Siblings", "<ol><li>One</li></ol><ol><li>Again</li></ol>", "1. One\n1. Again"},
+		{"Start", `<ol start="9"><li>Nine</li><li>Ten</li></ol>`, " 9. Nine\n10. Ten"},
+		{"Wide", "<ol>" + strings.Repeat("<li>x</li>", 9) + "<li>Some words that wrap</li></ol>", " 1. x\n 2. x\n 3. x\n 4. x\n 5. x\n 6. x\n 7. x\n 8. x\n 9. x\n10. Some words that\n    wrap"},
+		{"Value", `<ol><li>One</li><li value="5">Five</li><li>Six</li></ol>`, "1. One\n5. Five\n6. Six"},
+		{"Nested", "<ul><li>One<ol><li>Inner</li><li>Again</li></ol></li><li>Two</li></ul>", "• One\n  1. Inner\n  2. Again\n• Two"},
+		{"Hanging", "<ul><li>Some words that wrap</li></ul>", "• Some words that\n  wrap"},
+		{"Empty", "<ul><li></li><li>Two</li></ul>", "•\n• Two"},
+		{"NoList", "<li>Item</li>", "Item"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Width: 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestPreformatted(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Spaces", "<p>Before</p><pre>\n  x   y\n\n    z</pre><p>After</p>", "  Before\n  x   y\n\n    z\n  After"},
+		{"Tabs", "<pre>a\tb\n\tc</pre>", "a   b\n    c"},
+		{"HardWrap", "<pre>0123456789abcdefghijklmn</pre>", "0123456789abcdefghij\nklmn"},
+		{"TrailingBlankLines", "<pre>x\n\n\n</pre><p>After</p>", "x\n  After"},
+		{"Inline", "<pre>a <b>b</b>  c</pre>", "a b  c"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Width: 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+
+	// Styles still apply within preformatted text.
+	doc, err := Parse(strings.NewReader("<pre>a <b>b</b></pre>"), nil, Options{Width: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attr := doc.Cells[2].Fg; attr&termbox.AttrBold == 0 {
+		t.Errorf(expFormat, termbox.AttrBold, attr)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	src := "<p>a</p><blockquote><p>q</p><p>r</p></blockquote><p>b</p>"
+	doc, err := Parse(strings.NewReader(src), nil, Options{Layout: "compact"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every paragraph of the quotation is indented and dimmed.
+	exp := map[rune]termbox.Attribute{'a': termbox.ColorDefault, 'q': termbox.AttrDim, 'r': termbox.AttrDim, 'b': termbox.ColorDefault}
+	for i, cell := range doc.Cells {
+		attr, ok := exp[cell.Ch]
+		if !ok {
+			continue
+		}
+		if cell.Fg != attr {
+			t.Errorf(expFormat, attr, cell.Fg)
+		}
+		if x, indent := i%doc.Width, attr == termbox.AttrDim; indent != (x == blockIndent) {
+			t.Errorf("Expected %c to be indented: %v, but it is at column %d", cell.Ch, indent, x)
+		}
+	}
+}
+
+func TestWideCharacters(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		exp  string
+	}{
+		{"Japanese", "<p>吾輩は猫である。名前はまだ無い。</p>", "  吾輩は猫である。名\n前はまだ無い。"},
+		{"ClosingPunctuation", "<p>あいうえおかきくけ。</p>", "  あいうえおかきく\nけ。"},
+		{"Mixed", "<p>日本語 and English 「かぎ」です。</p>", "  日本語 and English\n「かぎ」です。"},
+		{"Combining", "<p>Cafe\u0301 q\u0323</p>", "  Caf\u00e9 q"},
+		{"Preformatted", "<pre>漢字漢字漢字漢字漢字漢字</pre>", "漢字漢字漢字漢字漢字\n漢字"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := Parse(strings.NewReader(tc.src), nil, Options{Width: 20})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text := doc.String(); text != tc.exp {
+				t.Errorf(expFormat, tc.exp, text)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	src := `<p>The cat sat on the mat with another cat</p><p>Cat food</p><p>吾輩は猫である。名前はまだ無い。</p>`
+	doc, err := Parse(strings.NewReader(src), nil, Options{Width: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		query string
+		exp   []Match
+	}{
+		{"IgnoresCase", "cat", []Match{{0, 6, 0, 9}, {1, 17, 1, 20}, {2, 2, 2, 5}}},
+		{"Wrapped", "the  MAT", []Match{{0, 17, 1, 3}}},
+		{"Paragraphs", "cat cat", nil},
+		{"Wide", "猫", []Match{{3, 8, 3, 10}}},
+		{"WideWrapped", "である。名前", []Match{{3, 10, 4, 2}}},
+		{"Empty", " ", nil},
+		{"Missing", "dog", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := doc.Find(tc.query); !slices.Equal(got, tc.exp) {
+				t.Errorf(expFormat, tc.exp, got)
+			}
+		})
+	}
+}
+
+func TestImageBlocks(t *testing.T) {
+	src := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10">` +
+		`<rect x="0" y="0" width="10" height="5" fill="red"/><rect x="0" y="5" width="10" height="5" fill="white"/></svg>`
+	testCases := []struct {
+		name    string
+		noColor bool
+		expCh   rune
+		expFg   termbox.Attribute
+		expBg   termbox.Attribute
+	}{
+		{"Color", false, upperHalfBlock, paletteColor(255, 0, 0), paletteColor(255, 255, 255)},
+		{"NoColor", true, 'O', termbox.ColorDefault, termbox.ColorDefault},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := Options{Width: 10, Images: true, ImageMode: ImageBlocks, NoColor: tc.noColor}
+			doc, err := Parse(strings.NewReader(src), nil, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The image is as many rows high as ASCII art would be, and
+			// each row shows two rows of pixels.
+			if rows := doc.Rows(); rows != 5 {
+				t.Fatalf(expFormat, 5, rows)
+			}
+			top := doc.Cells[0]
+			if top.Ch != tc.expCh || top.Fg&colorMask != tc.expFg || top.Bg&colorMask != tc.expFg {
+				t.Errorf(expFormat, termbox.Cell{Ch: tc.expCh, Fg: tc.expFg, Bg: tc.expFg}, top)
+			}
+			if bottom := doc.Cells[4*doc.Width]; bottom.Fg&colorMask != tc.expBg || bottom.Bg&colorMask != tc.expBg {
+				t.Errorf(expFormat, termbox.Cell{Ch: tc.expCh, Fg: tc.expBg, Bg: tc.expBg}, bottom)
+			}
+		})
+	}
+}
+
+func TestPaletteColor(t *testing.T) {
+	testCases := []struct {
+		r, g, b uint8
+		exp     int
+	}{
+		{0, 0, 0, 16},
+		{255, 255, 255, 231},
+		{255, 0, 0, 196},
+		{0, 135, 255, 33},
+		{128, 128, 128, 244},
+		{100, 104, 98, 241},
+	}
+
+	for _, tc := range testCases {
+		if got := paletteColor(tc.r, tc.g, tc.b); got != termbox.Attribute(tc.exp+1) {
+			t.Errorf(expFormat, tc.exp+1, got)
+		}
+	}
+}
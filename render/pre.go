@@ -0,0 +1,61 @@
+package render
+
+import "strings"
+
+// preElement is a preformatted element (<pre>) that is being parsed. Its text
+// is laid out verbatim rather than reflowed.
+type preElement struct {
+	// depth is the size of the tag stack when the element started.
+	depth int
+
+	// started records whether any of the element's text has been read, and
+	// newlines counts the line feeds read since text was last laid out.
+	started  bool
+	newlines int
+}
+
+// startPre starts a preformatted element on a new line.
+func (p *parser) startPre() {
+	p.doc.startLine()
+	p.doc.balance()
+	p.pre = &preElement{depth: len(p.tagStack)}
+}
+
+// appendPre appends the text of a preformatted element verbatim, keeping its
+// spaces and line feeds and expanding its tabs. Lines that do not fit are hard
+// wrapped at the right margin. A line feed just after the start tag is not
+// part of the content, and those at the end of the element are held back
+// until more text follows, so that they leave no blank lines after it.
+func (p *parser) appendPre(text string) {
+	pre := p.pre
+	if !pre.started {
+		text = strings.TrimPrefix(text, "\n")
+		pre.started = true
+	}
+
+	c := &p.doc
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			pre.newlines++
+		}
+		if line == "" {
+			continue
+		}
+		for ; pre.newlines > 0; pre.newlines-- {
+			c.row++
+			c.col = c.lmargin
+		}
+		if c.col < c.lmargin {
+			c.col = c.lmargin
+		}
+		c.appendRunes(line, c.fg)
+	}
+}
+
+// endPre ends the preformatted element, so that the text after it starts on a
+// new line.
+func (p *parser) endPre() {
+	p.pre = nil
+	p.doc.startLine()
+	p.doc.joined = false
+}
@@ -0,0 +1,140 @@
+package render
+
+import (
+	"strconv"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// rgb is a color given by its red, green and blue components.
+type rgb struct {
+	r, g, b int
+}
+
+// namedColors are the basic HTML color keywords.
+var namedColors = map[string]rgb{
+	"black":   {0, 0, 0},
+	"silver":  {192, 192, 192},
+	"gray":    {128, 128, 128},
+	"grey":    {128, 128, 128},
+	"white":   {255, 255, 255},
+	"maroon":  {128, 0, 0},
+	"red":     {255, 0, 0},
+	"purple":  {128, 0, 128},
+	"fuchsia": {255, 0, 255},
+	"magenta": {255, 0, 255},
+	"green":   {0, 128, 0},
+	"lime":    {0, 255, 0},
+	"olive":   {128, 128, 0},
+	"yellow":  {255, 255, 0},
+	"navy":    {0, 0, 128},
+	"blue":    {0, 0, 255},
+	"teal":    {0, 128, 128},
+	"aqua":    {0, 255, 255},
+	"cyan":    {0, 255, 255},
+	"orange":  {255, 165, 0},
+}
+
+// terminalColor is a color of a typical terminal's palette.
+type terminalColor struct {
+	attr termbox.Attribute
+	rgb
+}
+
+// terminalColors are the colors of a typical terminal's palette, other than
+// black and white.
+var terminalColors = []terminalColor{
+	{termbox.ColorRed, rgb{205, 0, 0}},
+	{termbox.ColorGreen, rgb{0, 205, 0}},
+	{termbox.ColorYellow, rgb{205, 205, 0}},
+	{termbox.ColorBlue, rgb{0, 0, 238}},
+	{termbox.ColorMagenta, rgb{205, 0, 205}},
+	{termbox.ColorCyan, rgb{0, 205, 205}},
+}
+
+// parseColor parses an HTML color, either a keyword or a hex value such as
+// "#ff0000" or "#f00".
+func parseColor(s string) (rgb, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedColors[s]; ok {
+		return c, true
+	}
+
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return rgb{}, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return rgb{}, false
+	}
+
+	return rgb{int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)}, true
+}
+
+// minSaturation is the least difference between the largest and smallest
+// components of a color for it to be shown in color rather than the default.
+const minSaturation = 64
+
+// fontColor returns the terminal color nearest to the color of a <font>
+// element. Grays, including black and white, which may be unreadable against
+// the terminal's background, and colors that cannot be parsed are left to the
+// default.
+func fontColor(token html.Token) termbox.Attribute {
+	if token.DataAtom != atom.Font {
+		return termbox.ColorDefault
+	}
+	c, ok := parseColor(tokenAttr(token, "color"))
+	if !ok || max(c.r, c.g, c.b)-min(c.r, c.g, c.b) < minSaturation {
+		return termbox.ColorDefault
+	}
+
+	return nearestColor(c, terminalColors)
+}
+
+// nearestColor returns the color of a palette nearest to c.
+func nearestColor(c rgb, palette []terminalColor) termbox.Attribute {
+	nearest, best := termbox.ColorDefault, -1
+	for _, tc := range palette {
+		dr, dg, db := c.r-tc.r, c.g-tc.g, c.b-tc.b
+		if d := dr*dr + dg*dg + db*db; best < 0 || d < best {
+			nearest, best = tc.attr, d
+		}
+	}
+	return nearest
+}
+
+// centerLine moves the text on the cursor's row to the middle of the text
+// column.
+func (c *Document) centerLine() {
+	row := c.row * c.Width
+	limit := c.Width - c.rmargin
+	first, last := -1, -1
+	for x := c.lmargin; x < limit && row+x < len(c.Cells); x++ {
+		if c.Cells[row+x].Ch != 0 {
+			if first < 0 {
+				first = x
+			}
+			last = x
+		}
+	}
+	if first < 0 {
+		return
+	}
+
+	shift := c.lmargin + (limit-c.lmargin-(last-first+1))/2 - first
+	if shift <= 0 {
+		return
+	}
+	c.grow(row + last + shift)
+	for x := last; x >= first; x-- {
+		c.Cells[row+x+shift] = c.Cells[row+x]
+		c.Cells[row+x] = termbox.Cell{}
+	}
+}
@@ -0,0 +1,89 @@
+package render
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Match is an occurrence of a search query in a document. Its text starts at
+// column Col of row Row and ends before column EndCol of row End.
+type Match struct {
+	Row, Col    int
+	End, EndCol int
+}
+
+// Find returns the occurrences of query in the document's text, in order,
+// ignoring case. The text is searched as it is read rather than as it is laid
+// out: the gap between two words is a single space whatever its width, and so
+// is the end of a line, so that phrases wrapped across lines are found.
+// Matches do not cross from one paragraph, or block of text, to the next.
+func (c Document) Find(query string) []Match {
+	q := []rune(strings.ToLower(strings.Join(strings.Fields(query), " ")))
+	if len(q) == 0 || c.Width <= 0 {
+		return nil
+	}
+
+	starts := make(map[int]bool, len(c.Paragraphs))
+	for _, p := range c.Paragraphs {
+		starts[p.Row] = true
+	}
+
+	// text is the document's text, lowered, and cells the index of the
+	// cell each of its runes is in, or -1 for the spaces that stand for
+	// gaps and the line feeds that separate paragraphs and those blocks of
+	// text set apart by blank rows.
+	var text []rune
+	var cells []int
+	gap, blank := false, false
+	for y := 0; y < c.Rows(); y++ {
+		empty := true
+		blank = blank || starts[y]
+		for x := 0; x < c.Width; x++ {
+			i := y*c.Width + x
+			var ch rune
+			if i < len(c.Cells) {
+				ch = c.Cells[i].Ch
+			}
+			if ch == 0 || unicode.IsSpace(ch) {
+				gap = true
+				continue
+			}
+
+			// Chinese and Japanese are written without spaces, so
+			// their lines are joined without one.
+			if n := len(text); n > 0 {
+				switch {
+				case blank:
+					text, cells = append(text, '\n'), append(cells, -1)
+				case gap && !(runeWidth(text[n-1]) == 2 && runeWidth(ch) == 2):
+					text, cells = append(text, ' '), append(cells, -1)
+				}
+			}
+			gap, blank, empty = false, false, false
+			text, cells = append(text, unicode.ToLower(ch)), append(cells, i)
+			if runeWidth(ch) == 2 {
+				x++
+			}
+		}
+		gap = true
+		blank = blank || empty
+	}
+
+	var matches []Match
+	for i := 0; i+len(q) <= len(text); i++ {
+		if !slices.Equal(text[i:i+len(q)], q) {
+			continue
+		}
+		start, end := cells[i], cells[i+len(q)-1]
+		matches = append(matches, Match{
+			Row:    start / c.Width,
+			Col:    start % c.Width,
+			End:    end / c.Width,
+			EndCol: end%c.Width + runeWidth(c.Cells[end].Ch),
+		})
+		i += len(q) - 1
+	}
+
+	return matches
+}
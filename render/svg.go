@@ -0,0 +1,167 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"io"
+	"path"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/srwiley/oksvg"
+	"github.com/srwiley/rasterx"
+	"github.com/taylorskalyo/goreader/epub"
+	"golang.org/x/net/html"
+)
+
+// svgMediaType is the media type of SVG images.
+const svgMediaType = "image/svg+xml"
+
+// svgRasterWidth is the width, in pixels, SVG images are rasterized at before
+// they are rendered as ASCII art. svgDefaultSize is the size of images that
+// do not give one.
+const (
+	svgRasterWidth = 480
+	svgDefaultSize = 100
+)
+
+// isSVG reports whether an item is an SVG image.
+func isSVG(item epub.Item) bool {
+	return item.MediaType == svgMediaType || strings.EqualFold(path.Ext(item.HREF), ".svg")
+}
+
+// decodeSVG rasterizes an SVG image onto a white background, scaled to
+// svgRasterWidth pixels wide. Only the shapes and paths of the image are
+// drawn; text and embedded images are left out.
+func decodeSVG(r io.Reader) (image.Image, error) {
+	icon, err := oksvg.ReadIconStream(r)
+	if err != nil {
+		return nil, err
+	}
+
+	w, h := icon.ViewBox.W, icon.ViewBox.H
+	if w <= 0 || h <= 0 {
+		w, h = svgDefaultSize, svgDefaultSize
+	}
+	width := svgRasterWidth
+	height := max(int(h*svgRasterWidth/w), 1)
+	icon.SetTarget(0, 0, float64(width), float64(height))
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	scanner := rasterx.NewScannerGV(width, height, img, img.Bounds())
+	icon.Draw(rasterx.NewDasher(width, height, scanner), 1)
+
+	return img, nil
+}
+
+// inlineSVG is an <svg> element embedded in a document.
+type inlineSVG struct {
+	// source is the element's markup, and size its length in the document
+	// and lines the number of line feeds in it.
+	source      []byte
+	size, lines int
+
+	// title is the text of the element's <title>, and href the source of
+	// the first <image> within it.
+	title string
+	href  string
+}
+
+// readSVG reads the tokens of an <svg> element up to and including its
+// closing tag. start is the markup of the element's start tag.
+func readSVG(z *html.Tokenizer, start []byte) inlineSVG {
+	var svg inlineSVG
+	var source, title bytes.Buffer
+	source.Write(start)
+	inTitle := false
+	for depth := 1; depth > 0; {
+		tokenType := z.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		source.Write(raw)
+		svg.size += len(raw)
+		svg.lines += bytes.Count(raw, []byte("\n"))
+
+		token := z.Token()
+		switch tokenType {
+		case html.StartTagToken:
+			switch token.Data {
+			case "svg":
+				depth++
+			case "title":
+				inTitle = title.Len() == 0
+			}
+			fallthrough
+		case html.SelfClosingTagToken:
+			if token.Data == "image" && svg.href == "" {
+				svg.href = tokenAttr(token, "href")
+				if svg.href == "" {
+					svg.href = tokenAttr(token, "xlink:href")
+				}
+			}
+		case html.EndTagToken:
+			switch token.Data {
+			case "svg":
+				depth--
+			case "title":
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle {
+				title.WriteString(token.Data)
+			}
+		}
+	}
+	svg.source = source.Bytes()
+	svg.title = strings.Join(strings.Fields(title.String()), " ")
+
+	return svg
+}
+
+// handleSVG reads an <svg> element embedded in the document and displays it
+// as ASCII art, in place of its markup. start is the markup of its start tag,
+// as it was read. An element that only holds an image (e.g. a cover) displays
+// that image. It is captioned like an image, by its label or title.
+func (p *parser) handleSVG(token html.Token, start []byte) {
+	p.pop(token.DataAtom)
+	row := p.doc.row
+	svg := readSVG(p.tokenizer, start)
+	p.read += svg.size
+	p.lines += svg.lines
+
+	var pic [][]termbox.Cell
+	if p.images {
+		opts := p.imageOpts
+		opts.Width = p.imageWidth()
+		if svg.href != "" {
+			if item, ok := p.item(svg.href); ok {
+				pic = itemPicture(item, opts)
+			}
+		} else if img, err := decodeSVG(bytes.NewReader(svg.source)); err == nil {
+			pic = picture(img, opts)
+		}
+	}
+
+	alt := strings.TrimSpace(tokenAttr(token, "aria-label"))
+	if alt == "" {
+		alt = svg.title
+	}
+	captioned := html.Token{Attr: []html.Attribute{
+		{Key: "src", Val: svg.href},
+		{Key: "title", Val: svg.title},
+	}}
+	if caption, ok := p.imageCaption(captioned, alt, len(pic) > 0); ok {
+		p.doc.startLine()
+		p.doc.appendText(caption + "\n")
+	}
+	if len(pic) > 0 {
+		p.appendImage(pic)
+	}
+	if svg.href != "" {
+		p.addResource(svg.href, row)
+	}
+}
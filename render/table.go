@@ -0,0 +1,39 @@
+package render
+
+import (
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Tables are laid out a row to a line, with their cells separated by spaces.
+// A caption is centered on its own line above the rows, and the cells that
+// head a row or column are shown in bold, so that they stand out from the
+// data.
+
+// startCaption starts a table's caption on a line of its own, centered.
+func (p *parser) startCaption() {
+	p.doc.startLine()
+	p.doc.centered = true
+}
+
+// endCaption ends a table's caption, leaving the rows to start on the next
+// line.
+func (p *parser) endCaption() {
+	p.doc.startLine()
+	p.doc.centered = p.within(atom.Center)
+}
+
+// headerCell returns the attribute of a cell that heads a row or column: a
+// <th>, or a <td> with a scope (e.g. scope="row"), as some books mark their
+// row headers. Other elements have none.
+func (p *parser) headerCell(token html.Token) termbox.Attribute {
+	scoped := strings.TrimSpace(tokenAttr(token, "scope")) != ""
+	if token.DataAtom == atom.Th || token.DataAtom == atom.Td && scoped {
+		return p.doc.theme.Bold
+	}
+
+	return termbox.ColorDefault
+}
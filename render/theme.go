@@ -0,0 +1,221 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// Theme gives the attributes text is displayed with according to its role. An
+// attribute may combine a color with text attributes such as bold. Colors of
+// inner elements replace those of outer ones, while text attributes are
+// combined.
+type Theme struct {
+	Name string
+
+	// Body is the attribute of ordinary text. Background is the color the
+	// pager is filled with behind it.
+	Body       termbox.Attribute
+	Background termbox.Attribute
+
+	// Bold is the attribute of bold and strong text. Italic is that of
+	// italic and emphasized text; when it is termbox.ColorDefault, the
+	// Italic option is used instead.
+	Bold   termbox.Attribute
+	Italic termbox.Attribute
+
+	// Headings are the attributes of headings, by level from h1 to h6, and
+	// Title that of the document's <title>.
+	Headings [6]termbox.Attribute
+	Title    termbox.Attribute
+
+	// Link is the attribute of hyperlinks, and Highlight that of marked text
+	// (<mark>).
+	Link      termbox.Attribute
+	Highlight termbox.Attribute
+
+	// Quote is the attribute of quoted passages (<blockquote>), which sets
+	// them apart from the text around them.
+	Quote termbox.Attribute
+
+	// SearchMatch and SearchCurrent are laid over the matches of a search
+	// and the current match, SelectedLink over the selected link and
+	// VisitedLink over links that have been followed (see Overlay).
+	SearchMatch   termbox.Attribute
+	SearchCurrent termbox.Attribute
+	SelectedLink  termbox.Attribute
+	VisitedLink   termbox.Attribute
+}
+
+// Themes are the built-in themes. The first is the default.
+var Themes = []Theme{
+	{
+		Name:     "default",
+		Bold:     termbox.AttrBold,
+		Headings: [6]termbox.Attribute{termbox.ColorMagenta, termbox.ColorBlue, termbox.ColorCyan, termbox.ColorCyan, termbox.ColorCyan, termbox.ColorCyan},
+		Title:    termbox.ColorRed,
+		Quote:    termbox.AttrDim,
+
+		SearchMatch:   termbox.ColorYellow | termbox.AttrReverse,
+		SearchCurrent: termbox.ColorGreen | termbox.AttrReverse | termbox.AttrBold,
+		SelectedLink:  termbox.AttrReverse,
+		VisitedLink:   termbox.ColorMagenta,
+	},
+
+	// light avoids the colors that are hard to read on a light background,
+	// such as yellow and cyan.
+	{
+		Name:      "light",
+		Bold:      termbox.AttrBold,
+		Italic:    termbox.AttrUnderline,
+		Headings:  [6]termbox.Attribute{termbox.ColorMagenta, termbox.ColorBlue, termbox.ColorGreen, termbox.ColorGreen, termbox.ColorGreen, termbox.ColorGreen},
+		Title:     termbox.ColorRed,
+		Link:      termbox.ColorBlue | termbox.AttrUnderline,
+		Highlight: termbox.AttrReverse,
+		Quote:     termbox.AttrDim,
+
+		SearchMatch:   termbox.ColorBlue | termbox.AttrReverse,
+		SearchCurrent: termbox.ColorMagenta | termbox.AttrReverse | termbox.AttrBold,
+		SelectedLink:  termbox.AttrReverse,
+		VisitedLink:   termbox.ColorMagenta,
+	},
+
+	// mono uses no colors at all.
+	{
+		Name:      "mono",
+		Bold:      termbox.AttrBold,
+		Italic:    termbox.AttrUnderline,
+		Headings:  [6]termbox.Attribute{termbox.AttrBold | termbox.AttrUnderline, termbox.AttrBold, termbox.AttrBold, termbox.AttrBold, termbox.AttrBold, termbox.AttrBold},
+		Title:     termbox.AttrBold,
+		Link:      termbox.AttrUnderline,
+		Highlight: termbox.AttrReverse,
+		Quote:     termbox.AttrDim,
+
+		SearchMatch:   termbox.AttrUnderline,
+		SearchCurrent: termbox.AttrReverse,
+		SelectedLink:  termbox.AttrReverse,
+		VisitedLink:   termbox.AttrDim,
+	},
+}
+
+// LookupTheme returns the built-in theme with the given name. An empty name
+// selects the default theme.
+func LookupTheme(name string) (Theme, bool) {
+	if name == "" {
+		return Themes[0], true
+	}
+	for _, t := range Themes {
+		if t.Name == name {
+			return t, true
+		}
+	}
+
+	return Themes[0], false
+}
+
+// Monochrome returns the theme without its colors, for terminals that cannot
+// show them. Roles the theme shows only by their color take the attribute
+// they have in the mono theme, so that they still stand out.
+func (t Theme) Monochrome() Theme {
+	mono, _ := LookupTheme("mono")
+	t.Body = t.Body &^ colorMask
+	t.Background = t.Background &^ colorMask
+	t.Bold = monochrome(t.Bold, mono.Bold)
+	t.Italic = monochrome(t.Italic, mono.Italic)
+	for i := range t.Headings {
+		t.Headings[i] = monochrome(t.Headings[i], mono.Headings[i])
+	}
+	t.Title = monochrome(t.Title, mono.Title)
+	t.Link = monochrome(t.Link, mono.Link)
+	t.Highlight = monochrome(t.Highlight, mono.Highlight)
+	t.Quote = monochrome(t.Quote, mono.Quote)
+	t.SearchMatch = monochrome(t.SearchMatch, mono.SearchMatch)
+	t.SearchCurrent = monochrome(t.SearchCurrent, mono.SearchCurrent)
+	t.SelectedLink = monochrome(t.SelectedLink, mono.SelectedLink)
+	t.VisitedLink = monochrome(t.VisitedLink, mono.VisitedLink)
+
+	return t
+}
+
+// Overlay returns the attribute of text shown with attr laid over fg: the
+// color of attr, if it has one, replaces that of fg, and its text attributes
+// are added to those of fg.
+func Overlay(fg, attr termbox.Attribute) termbox.Attribute {
+	if attr&colorMask != 0 {
+		fg = fg&^colorMask | attr&colorMask
+	}
+
+	return fg | attr&^colorMask
+}
+
+// monochrome returns an attribute without its color. An attribute that is
+// only a color is replaced by fallback.
+func monochrome(a, fallback termbox.Attribute) termbox.Attribute {
+	if a&colorMask != 0 && a&^colorMask == 0 {
+		return fallback
+	}
+
+	return a &^ colorMask
+}
+
+// themeColors are the colors that may be named in a theme. Terminals may
+// display them differently.
+var themeColors = map[string]termbox.Attribute{
+	"default": termbox.ColorDefault,
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+}
+
+// themePalette is the palette HTML color codes in a theme are matched to,
+// which unlike that of <font> colors includes black and white, since a theme
+// is chosen for the terminal it is used in.
+var themePalette = append([]terminalColor{
+	{termbox.ColorBlack, rgb{0, 0, 0}},
+	{termbox.ColorWhite, rgb{229, 229, 229}},
+}, terminalColors...)
+
+// themeAttributes are the text attributes that may be named in a theme.
+var themeAttributes = map[string]termbox.Attribute{
+	"bold":      termbox.AttrBold,
+	"underline": termbox.AttrUnderline,
+	"reverse":   termbox.AttrReverse,
+	"dim":       termbox.AttrDim,
+}
+
+// ParseAttribute parses the attribute of a role in a theme: a list of words
+// separated by spaces, each either a color (e.g. "cyan"), an HTML color code
+// (e.g. "#ff8000"), which is shown in the nearest terminal color, or a text
+// attribute (e.g. "bold"). At most one color may be given.
+func ParseAttribute(s string) (termbox.Attribute, error) {
+	var attr termbox.Attribute
+	colored := false
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		if a, ok := themeAttributes[word]; ok {
+			attr |= a
+			continue
+		}
+
+		color, ok := themeColors[word]
+		if !ok {
+			c, valid := parseColor(word)
+			if !valid || !strings.HasPrefix(word, "#") {
+				return 0, fmt.Errorf("unknown color or attribute %q", word)
+			}
+			color = nearestColor(c, themePalette)
+		}
+		if colored {
+			return 0, fmt.Errorf("more than one color in %q", s)
+		}
+		attr |= color
+		colored = true
+	}
+
+	return attr, nil
+}
@@ -0,0 +1,100 @@
+package render
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Tooltip is the title of an inline element, such as the expansion of an
+// abbreviation, which a browser would show when the element is hovered over.
+// The element's text covers rows Row to End.
+type Tooltip struct {
+	Row, End int
+	Text     string
+	Title    string
+}
+
+// tooltipElements are the inline elements whose titles are recorded as
+// tooltips.
+var tooltipElements = map[atom.Atom]bool{
+	atom.Abbr:    true,
+	atom.Acronym: true,
+	atom.Dfn:     true,
+	atom.Time:    true,
+	atom.Span:    true,
+	atom.A:       true,
+	atom.Cite:    true,
+	atom.Q:       true,
+	atom.Ins:     true,
+	atom.Del:     true,
+}
+
+// tooltipElement is an element with a title that is being parsed.
+type tooltipElement struct {
+	tag   atom.Atom
+	row   int
+	title string
+
+	// depth is the size of the tag stack when the element started.
+	depth int
+
+	// text holds the element's text.
+	text strings.Builder
+}
+
+// tooltipTitle returns the title of an element that carries a tooltip. A
+// <time> element without a title is described by its machine-readable date.
+func tooltipTitle(token html.Token) string {
+	if !tooltipElements[token.DataAtom] {
+		return ""
+	}
+	title := strings.TrimSpace(tokenAttr(token, "title"))
+	if title == "" && token.DataAtom == atom.Time {
+		title = strings.TrimSpace(tokenAttr(token, "datetime"))
+	}
+
+	return strings.Join(strings.Fields(title), " ")
+}
+
+// startTooltip starts an element that carries a tooltip, unless it is within
+// another.
+func (p *parser) startTooltip(token html.Token) {
+	if p.tooltip != nil || token.Type != html.StartTagToken {
+		return
+	}
+	if title := tooltipTitle(token); title != "" {
+		p.tooltip = &tooltipElement{
+			tag:   token.DataAtom,
+			row:   p.doc.row,
+			title: title,
+			depth: len(p.tagStack),
+		}
+	}
+}
+
+// endTooltip ends the current element that carries a tooltip. When
+// abbreviations are expanded, the first occurrence of each is followed by its
+// expansion in parentheses, e.g. "WHO (World Health Organization)".
+func (p *parser) endTooltip() {
+	t := p.tooltip
+	p.tooltip = nil
+	text := strings.Join(strings.Fields(t.text.String()), " ")
+	if text == "" {
+		return
+	}
+	p.doc.Tooltips = append(p.doc.Tooltips, Tooltip{Row: t.row, End: p.doc.row, Text: text, Title: t.title})
+
+	if !p.expandAbbreviations || t.tag != atom.Abbr && t.tag != atom.Acronym {
+		return
+	}
+	if p.abbreviations[text] || strings.Contains(text, t.title) {
+		return
+	}
+	if p.abbreviations == nil {
+		p.abbreviations = map[string]bool{}
+	}
+	p.abbreviations[text] = true
+	p.text.WriteString(" (" + t.title + ")")
+}
@@ -0,0 +1,87 @@
+package render
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+	termbox "github.com/nsf/termbox-go"
+)
+
+// runeWidth returns the number of columns r is displayed in: two for wide
+// characters, such as those of Chinese and Japanese, and zero for those that
+// are drawn over the character before them, such as combining marks, or not
+// drawn at all. Characters of ambiguous width take up one column, as they do
+// in termbox. Control characters are counted as one column, as they always
+// have been.
+func runeWidth(r rune) int {
+	if unicode.IsControl(r) {
+		return 1
+	}
+	w := runewidth.RuneWidth(r)
+	if w == 2 && runewidth.IsAmbiguousWidth(r) {
+		return 1
+	}
+
+	return w
+}
+
+// stringWidth returns the number of columns s is displayed in.
+func stringWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeWidth(r)
+	}
+
+	return n
+}
+
+// putRune writes r at the cursor with the given foreground attribute and
+// moves the cursor past it. A wide character takes up two cells, the second
+// of which holds a space that the terminal draws the character over.
+// Characters of no width are left out, since a cell holds a single rune.
+func (c *Document) putRune(r rune, fg termbox.Attribute) {
+	w := runeWidth(r)
+	if w == 0 {
+		return
+	}
+	c.setCell(c.col, c.row, r, fg, c.bg)
+	if w == 2 {
+		c.setCell(c.col+1, c.row, ' ', fg, c.bg)
+	}
+	c.col += w
+}
+
+// noBreakBefore and noBreakAfter are the punctuation marks of Chinese and
+// Japanese text that a line is not broken before (e.g. a full stop) and after
+// (e.g. an opening bracket).
+var (
+	noBreakBefore = "、。，．・：；？！）」』】〕〉》ー…‥々ゝゞヽヾ"
+	noBreakAfter  = "（「『【〔〈《"
+)
+
+// breakSegments splits a word into the parts it may be broken between at the
+// end of a line: at zero-width spaces, which are dropped, and around wide
+// characters, since Chinese and Japanese are written without spaces.
+func breakSegments(word string) []string {
+	var segs []string
+	var seg strings.Builder
+	var prev rune
+	for _, r := range word {
+		if r == zeroWidthSpace {
+			segs = append(segs, seg.String())
+			seg.Reset()
+			prev = 0
+			continue
+		}
+		if seg.Len() > 0 && (runeWidth(r) == 2 || runeWidth(prev) == 2) &&
+			!strings.ContainsRune(noBreakBefore, r) && !strings.ContainsRune(noBreakAfter, prev) {
+			segs = append(segs, seg.String())
+			seg.Reset()
+		}
+		seg.WriteRune(r)
+		prev = r
+	}
+
+	return append(segs, seg.String())
+}
@@ -0,0 +1,167 @@
+package render
+
+import (
+	"math"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// Line breaking algorithms, as named by Options.Wrap.
+const (
+	// WrapGreedy fills each line with as many words as fit before moving on
+	// to the next.
+	WrapGreedy = "greedy"
+
+	// WrapBalanced chooses where to break the lines of a paragraph so that
+	// they are of similar length, avoiding a very short last line.
+	WrapBalanced = "balanced"
+)
+
+// startRun marks the cursor as the start of a run of wrapped text, if one is
+// not already under way.
+func (c *Document) startRun() {
+	if !c.run {
+		c.run, c.runRow, c.runCol = true, c.row, c.col
+	}
+}
+
+// wrap moves the cursor to the start of the next line, within the current run
+// of wrapped text.
+func (c *Document) wrap() {
+	// Centered lines are not balanced, since they would be centered again.
+	if c.centered {
+		c.centerLine()
+		c.runSplit = true
+	}
+	c.row += 1 + c.lineSpacing
+	c.col = c.lmargin
+}
+
+// balance ends the current run of wrapped text. When lines are balanced, the
+// run's words are redistributed over the same number of lines so that the
+// lines are of similar length. Runs containing words that were broken across
+// lines, or that did not fit on a line, are left as they are.
+func (c *Document) balance() {
+	run, split := c.run, c.runSplit
+	c.run, c.runSplit = false, false
+	if !c.balanced || !run || split || c.row == c.runRow {
+		return
+	}
+
+	step := 1 + c.lineSpacing
+	limit := c.Width - c.rmargin
+	lines := (c.row-c.runRow)/step + 1
+
+	// Words are separated by cells that were never written to.
+	var words [][]termbox.Cell
+	var lengths []int
+	for y := c.runRow; y <= c.row; y += step {
+		start := c.lmargin
+		if y == c.runRow {
+			start = c.runCol
+		}
+		var word []termbox.Cell
+		for x := start; x <= limit; x++ {
+			var cell termbox.Cell
+			if i := y*c.Width + x; x < limit && i < len(c.Cells) {
+				cell = c.Cells[i]
+			}
+			if cell.Ch != 0 {
+				word = append(word, cell)
+			} else if len(word) > 0 {
+				words = append(words, word)
+				lengths = append(lengths, len(word))
+				word = nil
+			}
+		}
+	}
+
+	breaks, ok := balancedBreaks(lengths, limit-c.runCol, limit-c.lmargin, lines)
+	if !ok {
+		return
+	}
+
+	for y := c.runRow; y <= c.row; y += step {
+		start := c.lmargin
+		if y == c.runRow {
+			start = c.runCol
+		}
+		for x := start; x < limit; x++ {
+			if i := y*c.Width + x; i < len(c.Cells) {
+				c.Cells[i] = termbox.Cell{}
+			}
+		}
+	}
+
+	x, y := c.runCol, c.runRow
+	for i, word := range words {
+		if len(breaks) > 0 && i == breaks[0] {
+			breaks = breaks[1:]
+			x, y = c.lmargin, y+step
+		} else if i > 0 {
+			x++
+		}
+		for _, cell := range word {
+			c.setCell(x, y, cell.Ch, cell.Fg, cell.Bg)
+			x++
+		}
+	}
+	c.col = x
+	c.gapRow, c.gapCol = c.row, c.col
+}
+
+// balancedBreaks divides words of the given lengths, separated by single
+// spaces, into n lines so as to minimize the sum of the squares of the space
+// left at the end of each line. The first line holds up to first columns and
+// the others up to width. It returns the index of the word that starts each
+// line after the first, or false if the words do not fit.
+func balancedBreaks(lengths []int, first, width, n int) ([]int, bool) {
+	// cost[j][i] is the least cost of setting the first i words on j lines,
+	// and from[j][i] the word that starts the last of those lines.
+	cost := make([][]int, n+1)
+	from := make([][]int, n+1)
+	for j := range cost {
+		cost[j] = make([]int, len(lengths)+1)
+		from[j] = make([]int, len(lengths)+1)
+		for i := range cost[j] {
+			cost[j][i] = math.MaxInt
+		}
+	}
+	cost[0][0] = 0
+
+	for j := 1; j <= n; j++ {
+		capacity := width
+		if j == 1 {
+			capacity = first
+		}
+		for i := 1; i <= len(lengths); i++ {
+			// Widen the last line leftwards, one word at a time, until it no
+			// longer fits.
+			w := -1
+			for k := i - 1; k >= 0; k-- {
+				w += lengths[k] + 1
+				if w > capacity {
+					break
+				}
+				if cost[j-1][k] == math.MaxInt {
+					continue
+				}
+				slack := capacity - w
+				if total := cost[j-1][k] + slack*slack; total < cost[j][i] {
+					cost[j][i], from[j][i] = total, k
+				}
+			}
+		}
+	}
+	if cost[n][len(lengths)] == math.MaxInt {
+		return nil, false
+	}
+
+	breaks := make([]int, n-1)
+	for j, i := n, len(lengths); j > 1; j-- {
+		i = from[j][i]
+		breaks[j-2] = i
+	}
+
+	return breaks, true
+}
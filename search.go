@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// searchPrompt precedes the query being typed in the status bar.
+const searchPrompt = "/"
+
+// search reads a query typed into the status bar and goes to its first match
+// from the top of the viewport on. An empty query ends the search, clearing
+// its highlights.
+func (a *app) search() error {
+	query, ok, err := a.readCommand(searchPrompt)
+	if err != nil || !ok {
+		return err
+	}
+
+	a.query = query
+	a.pager.matches, a.pager.match = nil, 0
+	if query == "" {
+		return nil
+	}
+	a.pager.matches = a.pager.doc.Find(query)
+
+	return a.findNext(true)
+}
+
+// findNext goes to the next match of the search, or the previous one if
+// forward is false: the one after (or before) the current match, or the
+// viewport if there is none. When the chapter has no more matches the search
+// goes on in the chapters after (or before) it, going round from the end of
+// the book to the start, and back to the chapter it started in.
+func (a *app) findNext(forward bool) error {
+	if a.query == "" {
+		a.message = "No search; press / to search"
+		return nil
+	}
+
+	if a.nextMatch(forward) {
+		return nil
+	}
+
+	// Chapters laid out in vain are left for the position the search
+	// started from.
+	start := a.here()
+	from, n := a.chapter, len(a.book.Spine.Itemrefs)
+	for k := 1; k <= n; k++ {
+		i := (from + k) % n
+		if !forward {
+			i = (from - k + n) % n
+		}
+		if !a.chapterHas(i) {
+			continue
+		}
+		if i != a.chapter {
+			a.chapter = i
+			if err := a.openChapter(); err != nil {
+				return err
+			}
+		}
+		if len(a.pager.matches) == 0 {
+			continue
+		}
+
+		if forward {
+			a.selectMatch(0)
+		} else {
+			a.selectMatch(len(a.pager.matches) - 1)
+		}
+		if forward && i <= from || !forward && i >= from {
+			a.message = "Search wrapped; " + a.message
+		}
+		return nil
+	}
+
+	a.message = "Not found: " + a.query
+	return a.toPosition(start)
+}
+
+// nextMatch selects the next match of the search in the chapter, or the
+// previous one if forward is false, starting from the current match or else
+// the top of the viewport. It returns false if there is none.
+func (a *app) nextMatch(forward bool) bool {
+	matches := a.pager.matches
+	j := -1
+	switch {
+	case a.pager.match > 0 && forward:
+		j = a.pager.match
+	case a.pager.match > 0:
+		j = a.pager.match - 2
+	case forward:
+		j = len(matches)
+		for i, m := range matches {
+			if m.End >= a.pager.scrollY {
+				j = i
+				break
+			}
+		}
+	default:
+		for i, m := range matches {
+			if m.Row < a.pager.scrollY {
+				j = i
+			}
+		}
+	}
+	if j < 0 || j >= len(matches) {
+		return false
+	}
+	a.selectMatch(j)
+
+	return true
+}
+
+// selectMatch makes the match at index i of the chapter's the current one,
+// scrolling to it if it is not on screen.
+func (a *app) selectMatch(i int) {
+	m := a.pager.matches[i]
+	a.pager.match = i + 1
+	if _, viewHeight := viewSize(); m.Row < a.pager.scrollY || m.End >= a.pager.scrollY+viewHeight {
+		a.pager.toRow(m.Row)
+	}
+	a.message = fmt.Sprintf("%s: %d of %d in chapter", a.query, i+1, len(a.pager.matches))
+}
+
+// chapterHas reports whether the text of the chapter at index i of the spine
+// may hold a match of the search, so that only the chapters that do are laid
+// out to find where. Chapters that cannot be read have none.
+func (a *app) chapterHas(i int) bool {
+	text, err := a.text.chapter(i)
+	if err != nil {
+		return false
+	}
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+
+	return strings.Contains(text, strings.ToLower(strings.Join(strings.Fields(a.query), " ")))
+}
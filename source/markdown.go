@@ -0,0 +1,100 @@
+package source
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+)
+
+// markdownExts are the extensions of Markdown files.
+var markdownExts = []string{".md", ".markdown"}
+
+// isMarkdown reports whether the file at name is a Markdown file, by its
+// extension.
+func isMarkdown(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range markdownExts {
+		if ext == e {
+			return true
+		}
+	}
+
+	return false
+}
+
+// openMarkdown converts Markdown to HTML and presents it as a book with a
+// single chapter. Images stored locally, at paths relative to dir, are added
+// to the book; others are left to be shown by their alt text.
+func openMarkdown(r io.Reader, title, dir string) (*Book, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !isText(src) {
+		return nil, ErrUnknownFormat
+	}
+
+	// Raw HTML is kept, since it is displayed by goreader rather than a
+	// browser.
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+	doc := md.Parser().Parse(text.NewReader(src))
+	images := addImages(doc, dir)
+
+	var body bytes.Buffer
+	if err := md.Renderer().Render(&body, src, doc); err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("markdown:%x", sha1.Sum(src))
+
+	return wrapHTML(title, id, body.String(), images)
+}
+
+// addImages reads the local images a Markdown document refers to, relative to
+// dir, and points the document at their paths within the book, which are
+// returned with their content. Images that cannot be read, or that are given
+// by a URL, are left as they are.
+func addImages(doc ast.Node, dir string) map[string][]byte {
+	images := map[string][]byte{}
+	added := map[string]string{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		img, ok := n.(*ast.Image)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		u, err := url.Parse(string(img.Destination))
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+			return ast.WalkContinue, nil
+		}
+		name, ok := added[u.Path]
+		if !ok {
+			b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(u.Path)))
+			if err != nil {
+				return ast.WalkContinue, nil
+			}
+			name = fmt.Sprintf("images/%d%s", len(images)+1, path.Ext(u.Path))
+			images[name] = b
+			added[u.Path] = name
+		}
+		img.Destination = []byte(name)
+
+		return ast.WalkContinue, nil
+	})
+
+	return images
+}
@@ -0,0 +1,278 @@
+/*
+Package source opens books from epub files, unpacked epub directories, plain
+text and Markdown files, and standard input. Epubs, Markdown and plain text may
+be gzip compressed.
+*/
+
+package source
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/taylorskalyo/goreader/epub"
+)
+
+// StdinName is the file name that refers to standard input.
+const StdinName = "-"
+
+// zipMagic is the signature at the start of a zip archive, and so of an epub.
+var zipMagic = []byte("PK\x03\x04")
+
+// gzipMagic is the signature at the start of gzip compressed data.
+var gzipMagic = []byte("\x1f\x8b")
+
+// ErrUnknownFormat occurs when a source is neither an epub nor plain text.
+var ErrUnknownFormat = errors.New("unrecognized file format")
+
+// Book is an open book, read from an epub or a plain text source.
+type Book struct {
+	*epub.Rootfile
+
+	// close releases any resources held by the book.
+	close func()
+}
+
+// Open opens the book at name, which may be an epub file, an unpacked
+// epub directory, a Markdown file (named *.md or *.markdown), or a plain text
+// file. Epub, Markdown and plain text files may be gzip compressed (e.g.
+// book.txt.gz).
+// A name of "-" reads the book from standard input.
+func Open(name string) (*Book, error) {
+	if name == StdinName {
+		return OpenStream(os.Stdin, "stdin")
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return openEPUB(name)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(zipMagic))
+	if _, err := io.ReadFull(f, magic); err == nil && bytes.Equal(magic, zipMagic) {
+		return openEPUB(name)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if base := strings.TrimSuffix(filepath.Base(name), ".gz"); bytes.HasPrefix(magic, gzipMagic) {
+		if !isMarkdown(base) {
+			return OpenStream(f, base)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return openMarkdown(gz, base, filepath.Dir(name))
+	}
+	if isMarkdown(name) {
+		return openMarkdown(f, filepath.Base(name), filepath.Dir(name))
+	}
+
+	return openText(f, filepath.Base(name))
+}
+
+// Close releases any resources held by the book.
+func (b *Book) Close() {
+	b.close()
+}
+
+// openEPUB opens an epub file or unpacked epub directory.
+func openEPUB(name string) (*Book, error) {
+	rc, err := epub.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Book{Rootfile: rc.Rootfiles[0], close: rc.Close}, nil
+}
+
+// OpenStream opens a book from a stream that cannot be read at random. Epubs
+// are first copied to a temporary file; plain text is read directly. Gzip
+// compressed streams are decompressed as they are read.
+func OpenStream(r io.Reader, title string) (*Book, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zipMagic))
+	if bytes.HasPrefix(magic, gzipMagic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return OpenStream(gz, title)
+	}
+	if !bytes.Equal(magic, zipMagic) {
+		return openText(br, title)
+	}
+
+	tmp, err := os.CreateTemp("", "goreader-*.epub")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	if _, err := io.Copy(tmp, br); err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	b, err := openEPUB(tmp.Name())
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	closeEPUB := b.close
+	b.close = func() {
+		closeEPUB()
+		cleanup()
+	}
+
+	return b, nil
+}
+
+// openText reads plain text and presents it as a book with a single chapter.
+// Paragraphs are separated by blank lines; other line breaks are reflowed.
+func openText(r io.Reader, title string) (*Book, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !isText(text) {
+		return nil, ErrUnknownFormat
+	}
+
+	var body strings.Builder
+	for _, para := range splitParagraphs(string(text)) {
+		fmt.Fprintf(&body, "<p>%s</p>\n", html.EscapeString(para))
+	}
+	id := fmt.Sprintf("text:%x", sha1.Sum(text))
+
+	return wrapHTML(title, id, body.String(), nil)
+}
+
+// wrapHTML presents the HTML body of a document as a book with a single
+// chapter, by wrapping it in a minimal epub so that it can be displayed like
+// any other book. resources holds the files the document refers to, such as
+// images, by their path within the book.
+func wrapHTML(title, id, body string, resources map[string][]byte) (*Book, error) {
+	var items strings.Builder
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		mediaType := mime.TypeByExtension(path.Ext(name))
+		if mediaType == "" {
+			mediaType = "application/octet-stream"
+		}
+		fmt.Fprintf(&items, "\n    <item id=\"res%d\" href=\"%s\" media-type=\"%s\"/>", i+1, html.EscapeString(name), html.EscapeString(mediaType))
+	}
+
+	files := map[string][]byte{
+		"META-INF/container.xml": []byte(`<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>`),
+		"content.opf": []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>%s</dc:title>
+    <dc:identifier>%s</dc:identifier>
+  </metadata>
+  <manifest>
+    <item id="text" href="text.html" media-type="application/xhtml+xml"/>%s
+  </manifest>
+  <spine>
+    <itemref idref="text"/>
+  </spine>
+</package>`, html.EscapeString(title), id, items.String())),
+		"text.html": []byte("<html><body>\n" + body + "</body></html>"),
+	}
+	for name, b := range resources {
+		files[name] = b
+	}
+
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := z.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(content); err != nil {
+			return nil, err
+		}
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+
+	er, err := epub.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Book{Rootfile: er.Rootfiles[0], close: func() {}}, nil
+}
+
+// isText reports whether b appears to be plain text: valid UTF-8 without NUL
+// bytes.
+func isText(b []byte) bool {
+	return utf8.Valid(b) && bytes.IndexByte(b, 0) < 0
+}
+
+// splitParagraphs splits text into paragraphs at blank lines, joining the
+// lines within each paragraph with spaces. Lines may end with a line feed, a
+// carriage return and line feed (as on Windows) or a lone carriage return.
+func splitParagraphs(text string) []string {
+	var paras []string
+	var lines []string
+	flush := func() {
+		if len(lines) > 0 {
+			paras = append(paras, strings.Join(lines, " "))
+			lines = nil
+		}
+	}
+
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	return paras
+}
@@ -0,0 +1,218 @@
+package source
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const expFormat = "Expected: %v, but got: %v\n"
+
+func TestOpenStream(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		b, err := OpenStream(strings.NewReader("One\ntwo.\n\nThree & four.\n"), "stdin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+
+		if b.Title != "stdin" {
+			t.Errorf(expFormat, "stdin", b.Title)
+		}
+		if n := len(b.Spine.Itemrefs); n != 1 {
+			t.Fatalf(expFormat, 1, n)
+		}
+
+		rc, err := b.Spine.Itemrefs[0].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, exp := range []string{"<p>One two.</p>", "<p>Three &amp; four.</p>"} {
+			if !strings.Contains(string(content), exp) {
+				t.Errorf(expFormat, exp, string(content))
+			}
+		}
+	})
+
+	t.Run("EPUB", func(t *testing.T) {
+		f, err := os.Open("../epub/_test_files/alice.epub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		b, err := OpenStream(f, "stdin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+
+		if exp := "Lewis Carroll"; b.Creator != exp {
+			t.Errorf(expFormat, exp, b.Creator)
+		}
+	})
+
+	t.Run("Gzip", func(t *testing.T) {
+		b, err := OpenStream(bytes.NewReader(gzipped(t, "One.\n")), "stdin")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+
+		if n := len(b.Spine.Itemrefs); n != 1 {
+			t.Errorf(expFormat, 1, n)
+		}
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		if _, err := OpenStream(strings.NewReader("\x00\x01\x02"), "stdin"); err != ErrUnknownFormat {
+			t.Errorf(expFormat, ErrUnknownFormat, err)
+		}
+	})
+}
+
+func TestOpenGzip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "book.txt.gz")
+	if err := os.WriteFile(name, gzipped(t, "One.\n\nTwo.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if exp := "book.txt"; b.Title != exp {
+		t.Errorf(expFormat, exp, b.Title)
+	}
+}
+
+func TestOpenGzipMarkdown(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "notes.md.gz")
+	if err := os.WriteFile(name, gzipped(t, "# Title\n\nSome *emphasis*.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if exp := "notes.md"; b.Title != exp {
+		t.Errorf(expFormat, exp, b.Title)
+	}
+	rc, err := b.Spine.Itemrefs[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "<em>emphasis</em>"; !strings.Contains(string(content), exp) {
+		t.Errorf(expFormat, exp, string(content))
+	}
+}
+
+func TestOpenMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	src := "# Title\n\nSome *emphasis* and `code`.\n\n- one\n- two\n\n" +
+		"![A map](img/map.png) ![Again](img/map.png) ![Remote](https://example.com/x.png) ![Gone](gone.png)\n"
+	name := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(name, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "img"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "img", "map.png"), []byte("PNG"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if exp := "notes.md"; b.Title != exp {
+		t.Errorf(expFormat, exp, b.Title)
+	}
+	rc, err := b.Spine.Itemrefs[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range []string{
+		"<h1>Title</h1>",
+		"<em>emphasis</em>",
+		"<li>one</li>",
+		`<img src="images/1.png" alt="A map">`,
+		`<img src="images/1.png" alt="Again">`,
+		`<img src="https://example.com/x.png" alt="Remote">`,
+		`<img src="gone.png" alt="Gone">`,
+	} {
+		if !strings.Contains(string(content), exp) {
+			t.Errorf(expFormat, exp, string(content))
+		}
+	}
+
+	var items []string
+	for _, item := range b.Manifest.Items {
+		items = append(items, item.HREF+" "+item.MediaType)
+	}
+	if exp := []string{"text.html application/xhtml+xml", "images/1.png image/png"}; !slices.Equal(items, exp) {
+		t.Errorf(expFormat, exp, items)
+	}
+}
+
+// gzipped returns text, gzip compressed.
+func gzipped(t *testing.T, text string) []byte {
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	if _, err := z.Write([]byte(text)); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestSplitParagraphs(t *testing.T) {
+	exp := []string{"One two.", "Three."}
+	testCases := []struct {
+		name string
+		text string
+	}{
+		{"LF", "One\ntwo.\n\nThree.\n"},
+		{"CRLF", "One\r\ntwo.\r\n\r\nThree.\r\n"},
+		{"CR", "One\rtwo.\r\rThree.\r"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if paras := splitParagraphs(tc.text); !slices.Equal(paras, exp) {
+				t.Errorf(expFormat, exp, paras)
+			}
+		})
+	}
+}
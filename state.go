@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/taylorskalyo/goreader/epub"
+	"github.com/taylorskalyo/goreader/source"
+)
+
+// state holds information that is persisted between sessions.
+type state struct {
+	Books map[string]*bookState `json:"books"`
+}
+
+// bookState holds the persisted state of a single book.
+type bookState struct {
+	// Settings holds the settings that override the global ones for the
+	// book, by their names in the config file (see settings.overrides).
+	Settings map[string]json.RawMessage `json:"settings,omitempty"`
+
+	// Position, when set, is where the book was last left, and Furthest the
+	// furthest into the book the reader has been.
+	Position *position `json:"position,omitempty"`
+	Furthest *position `json:"furthest,omitempty"`
+
+	// Bookmarks are the places in the book the reader has named, in the
+	// order they were made.
+	Bookmarks []bookmark `json:"bookmarks,omitempty"`
+}
+
+// setBookmark adds a bookmark to the book, replacing any with the same name.
+func (bs *bookState) setBookmark(b bookmark) {
+	for i := range bs.Bookmarks {
+		if bs.Bookmarks[i].Name == b.Name {
+			bs.Bookmarks[i] = b
+			return
+		}
+	}
+	bs.Bookmarks = append(bs.Bookmarks, b)
+}
+
+// position is a place in a book: a row of the rendered chapter at index
+// Chapter of the spine.
+type position struct {
+	Chapter int `json:"chapter"`
+	Row     int `json:"row"`
+
+	// Offset, when set, is the position in the chapter's source of the
+	// text at the top of the viewport, by which the place is found again
+	// once the chapter is laid out at another width or with other settings,
+	// when Row no longer holds the same text.
+	Offset int `json:"offset,omitempty"`
+}
+
+// bookmark is a place in a book that the reader has named.
+type bookmark struct {
+	Name string `json:"name"`
+	position
+}
+
+// after reports whether p is further into the book than q. Places in the
+// same chapter are compared by their source offsets when both have one,
+// since their rows may be those of different layouts.
+func (p position) after(q position) bool {
+	if p.Chapter != q.Chapter {
+		return p.Chapter > q.Chapter
+	}
+	if p.Offset > 0 && q.Offset > 0 {
+		return p.Offset > q.Offset
+	}
+
+	return p.Row > q.Row
+}
+
+// statePath returns the location of the state file.
+func statePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "state.json"), nil
+}
+
+// loadState reads the state file. An empty state is returned along with any
+// error, so callers may carry on without persisted state.
+func loadState() (*state, error) {
+	s := &state{Books: make(map[string]*bookState)}
+
+	path, err := statePath()
+	if err != nil {
+		return s, err
+	}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+
+	if err := json.Unmarshal(b, s); err != nil {
+		return &state{Books: make(map[string]*bookState)}, err
+	}
+	if s.Books == nil {
+		s.Books = make(map[string]*bookState)
+	}
+
+	return s, nil
+}
+
+// save writes the state file, replacing it atomically.
+func (s *state) save() error {
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// book returns the state of the book identified by key, creating it if
+// needed.
+func (s *state) book(key string) *bookState {
+	bs := s.Books[key]
+	if bs == nil {
+		bs = new(bookState)
+		s.Books[key] = bs
+	}
+
+	return bs
+}
+
+// bookKey returns the key a book's state is stored under. The book's
+// identifier is preferred, since it survives the file being moved; otherwise
+// the absolute path of the file is used.
+func bookKey(name string, book *epub.Rootfile) string {
+	if book.Identifier != "" {
+		return book.Identifier
+	}
+	if name == source.StdinName {
+		return name
+	}
+
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+
+	return name
+}
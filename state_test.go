@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestPositionAfter(t *testing.T) {
+	testCases := []struct {
+		p, q position
+		exp  bool
+	}{
+		{position{Chapter: 1, Row: 0}, position{Chapter: 0, Row: 50}, true},
+		{position{Chapter: 1, Row: 20}, position{Chapter: 1, Row: 10}, true},
+		{position{Chapter: 1, Row: 10}, position{Chapter: 1, Row: 10}, false},
+		{position{Chapter: 0, Row: 90}, position{Chapter: 1, Row: 0}, false},
+
+		// Offsets, when both are set, outlast the rows of another layout.
+		{position{Chapter: 1, Row: 10, Offset: 500}, position{Chapter: 1, Row: 20, Offset: 300}, true},
+		{position{Chapter: 1, Row: 20, Offset: 300}, position{Chapter: 1, Row: 10, Offset: 500}, false},
+		{position{Chapter: 1, Row: 20, Offset: 300}, position{Chapter: 1, Row: 10}, true},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.p.after(tc.q); got != tc.exp {
+			t.Errorf(expFormat, tc.exp, got)
+		}
+	}
+}
+
+func TestSetBookmark(t *testing.T) {
+	var bs bookState
+	bs.setBookmark(bookmark{Name: "start", position: position{Chapter: 0, Row: 5}})
+	bs.setBookmark(bookmark{Name: "notes", position: position{Chapter: 3, Row: 0, Offset: 120}})
+	bs.setBookmark(bookmark{Name: "start", position: position{Chapter: 1, Row: 2}})
+
+	exp := []bookmark{
+		{Name: "start", position: position{Chapter: 1, Row: 2}},
+		{Name: "notes", position: position{Chapter: 3, Row: 0, Offset: 120}},
+	}
+	if !slices.Equal(bs.Bookmarks, exp) {
+		t.Errorf(expFormat, exp, bs.Bookmarks)
+	}
+}
+
+func TestBookmarkJSON(t *testing.T) {
+	b := bookmark{Name: "notes", position: position{Chapter: 3, Row: 7, Offset: 120}}
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"name":"notes","chapter":3,"row":7,"offset":120}`
+	if string(data) != exp {
+		t.Errorf(expFormat, exp, string(data))
+	}
+
+	var got bookmark
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != b {
+		t.Errorf(expFormat, b, got)
+	}
+}
+
+func TestSettingsOverrides(t *testing.T) {
+	global := defaultSettings
+	book := global
+	book.Margin, book.Layout = 4, "article"
+
+	overrides := book.overrides(global)
+	if len(overrides) != 2 || string(overrides["margin"]) != "4" || string(overrides["layout"]) != `"article"` {
+		t.Errorf(expFormat, `{"layout":"article","margin":4}`, overrides)
+	}
+	if book.overrides(book) != nil {
+		t.Errorf(expFormat, "no overrides", book.overrides(book))
+	}
+
+	// Global settings the book does not override still reach it.
+	global.LineSpacing = 2
+	got := global.override(overrides)
+	if got.Margin != 4 || got.Layout != "article" || got.LineSpacing != 2 {
+		t.Errorf(expFormat, "margin 4, article layout and line spacing 2", got)
+	}
+
+	// Settings of the wrong type are left as they are.
+	got = global.override(map[string]json.RawMessage{"margin": json.RawMessage(`"wide"`), "paged": json.RawMessage("true")})
+	if got.Margin != global.Margin || !got.Paged {
+		t.Errorf(expFormat, "the global margin and paged view", got)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// session records a single reading session in the stats log.
+type session struct {
+	Book  string    `json:"book"`
+	Title string    `json:"title"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// StartPercent and EndPercent are the reader's progress through the book
+	// when the session started and ended.
+	StartPercent float64 `json:"start_percent"`
+	EndPercent   float64 `json:"end_percent"`
+}
+
+// statsPath returns the location of the stats log.
+func statsPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "stats.jsonl"), nil
+}
+
+// statsLogger appends sessions to the stats log in the background, so that
+// writing never holds up the user interface.
+type statsLogger struct {
+	sessions chan session
+	done     chan struct{}
+}
+
+// newStatsLogger starts a statsLogger that writes to the stats log.
+func newStatsLogger() *statsLogger {
+	l := &statsLogger{
+		sessions: make(chan session, 16),
+		done:     make(chan struct{}),
+	}
+	go l.run()
+
+	return l
+}
+
+// run writes sessions until the logger is closed. Failures to write are
+// ignored; statistics are a convenience and must never interrupt reading.
+func (l *statsLogger) run() {
+	defer close(l.done)
+	for s := range l.sessions {
+		path, err := statsPath()
+		if err != nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			continue
+		}
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			continue
+		}
+		json.NewEncoder(f).Encode(s)
+		f.Close()
+	}
+}
+
+// log queues a session to be written. If the queue is full the session is
+// dropped rather than blocking.
+func (l *statsLogger) log(s session) {
+	select {
+	case l.sessions <- s:
+	default:
+	}
+}
+
+// close waits for queued sessions to be written and stops the logger.
+func (l *statsLogger) close() {
+	close(l.sessions)
+	<-l.done
+}
+
+// startSession begins recording a reading session.
+func (a *app) startSession() {
+	a.session = session{
+		Book:         a.key,
+		Title:        a.book.Title,
+		Start:        time.Now(),
+		StartPercent: a.progress(),
+	}
+}
+
+// endSession finishes the current reading session and logs it.
+func (a *app) endSession() {
+	if a.stats == nil {
+		return
+	}
+
+	s := a.session
+	s.End = time.Now()
+	s.EndPercent = a.progress()
+	a.stats.log(s)
+}
+
+// progress returns how far through the book the reader is, as a percentage.
+func (a *app) progress() float64 {
+	n := len(a.book.Spine.Itemrefs)
+	if n == 0 {
+		return 0
+	}
+
+	var fraction float64
+	if _, height := a.pager.size(); height > 0 {
+		fraction = float64(a.pager.scrollY) / float64(height)
+		if fraction > 1 {
+			fraction = 1
+		}
+	}
+
+	return (float64(a.chapter) + fraction) / float64(n) * 100
+}
+
+// bookStats totals the sessions logged for a book.
+type bookStats struct {
+	title    string
+	sessions int
+	time     time.Duration
+	percent  float64
+}
+
+// printStats writes a summary of the stats log to w, with totals per book.
+func printStats(w io.Writer) error {
+	path, err := statsPath()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		fmt.Fprintln(w, "No reading sessions have been recorded.")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	totals := make(map[string]*bookStats)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s session
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			// Skip lines that were only partially written.
+			continue
+		}
+
+		bs := totals[s.Book]
+		if bs == nil {
+			bs = new(bookStats)
+			totals[s.Book] = bs
+		}
+		if s.Title != "" {
+			bs.title = s.Title
+		}
+		bs.sessions++
+		bs.time += s.End.Sub(s.Start)
+		if advanced := s.EndPercent - s.StartPercent; advanced > 0 {
+			bs.percent += advanced
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(totals))
+	for key, bs := range totals {
+		if bs.title == "" {
+			bs.title = key
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return totals[keys[i]].title < totals[keys[j]].title
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "BOOK\tSESSIONS\tTIME\tADVANCED")
+	for _, key := range keys {
+		bs := totals[key]
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%.1f%%\n", bs.title, bs.sessions, bs.time.Round(time.Second), bs.percent)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/taylorskalyo/goreader/epub"
+	"github.com/taylorskalyo/goreader/render"
+)
+
+func TestStatus(t *testing.T) {
+	book := &epub.Rootfile{}
+	book.Title = "Alice"
+	book.Spine.Itemrefs = make([]epub.Itemref, 3)
+	a := &app{book: book, chapter: 1}
+	a.pager.doc = render.Document{
+		Cells:    make([]termbox.Cell, 100),
+		Width:    10,
+		Headings: []render.Heading{{Row: 0, Level: 1, Title: "Down the Rabbit-Hole"}},
+		Pages:    []render.Page{{Row: 0, Label: "12"}},
+	}
+
+	testCases := []struct {
+		format      string
+		left, right string
+	}{
+		{defaultStatus, "Down the Rabbit-Hole", "Page 12"},
+		{"{book}: {chapter} — {percent}% [{item}/{total}]", "Alice: Down the Rabbit-Hole — 33% [2/3]", ""},
+		{"{chapter}\t{unknown}", "Down the Rabbit-Hole", "{unknown}"},
+		{"{item}\t {screen}", "2", "1/1"},
+		{"", "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.format, func(t *testing.T) {
+			a.config.Status = tc.format
+			left, right := a.status()
+			if left != tc.left || right != tc.right {
+				t.Errorf(expFormat, tc.left+"|"+tc.right, left+"|"+right)
+			}
+		})
+	}
+}
+
+func TestChapterLeft(t *testing.T) {
+	doc, err := render.Parse(strings.NewReader("<p>One two three.</p><p>Four five.</p><br/><br/><p>Six</p>"), nil, render.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &app{}
+	a.pager.doc = doc
+	a.config.WordsPerMinute = 2
+
+	testCases := []struct {
+		scrollY int
+		exp     string
+	}{
+		{0, "3 lines / ~3 min left"},
+		{1, "2 lines / ~2 min left"},
+		{doc.Rows() - 1, "1 line / ~1 min left"},
+		{doc.Rows(), "0 lines / ~0 min left"},
+	}
+
+	for _, tc := range testCases {
+		a.pager.scrollY = tc.scrollY
+		if left := a.chapterLeft(); left != tc.exp {
+			t.Errorf(expFormat, tc.exp, left)
+		}
+	}
+}
+
+func TestBookText(t *testing.T) {
+	rc, err := epub.OpenReader("epub/_test_files/alice.epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	book := rc.Rootfiles[0]
+
+	text := newBookText(book, "", "")
+	last := len(book.Spine.Itemrefs) - 1
+	s, err := text.chapter(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := text.wordCount(last); n == 0 || n != len(strings.Fields(s)) {
+		t.Errorf(expFormat, len(strings.Fields(s)), n)
+	}
+	if strings.Contains(s, "\n\n") || s != strings.TrimSpace(s) {
+		t.Errorf("Expected trimmed lines without blank lines, but got: %q", s)
+	}
+
+	// The chapter used longest ago is dropped once the text held exceeds
+	// the limit, but its word count is kept.
+	text.size += maxCachedText
+	if _, err := text.chapter(0); err != nil {
+		t.Fatal(err)
+	}
+	if len(text.text) != 1 || text.used[0] != 0 {
+		t.Errorf(expFormat, []int{0}, text.used)
+	}
+	if _, ok := text.words[last]; !ok {
+		t.Errorf(expFormat, "a word count", "none")
+	}
+}
+
+func TestBookTextCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	b, err := os.ReadFile("epub/_test_files/alice.epub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "alice.epub")
+	if err := os.WriteFile(name, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := epub.OpenReader(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	book := rc.Rootfiles[0]
+	last := len(book.Spine.Itemrefs) - 1
+
+	s, err := newBookText(book, name, "alice").chapter(last)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := newBookText(book, name, "alice")
+	text.openCache()
+	path := filepath.Join(text.dir, strconv.Itoa(last)+".txt")
+	if cached, err := os.ReadFile(path); err != nil || string(cached) != s {
+		t.Fatalf(expFormat, s, string(cached))
+	}
+
+	// Text is read from the cache in later sessions.
+	if err := os.WriteFile(path, []byte("cached"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := text.chapter(last); s != "cached" {
+		t.Errorf(expFormat, "cached", s)
+	}
+
+	// It is extracted again once the book's file changes.
+	if err := os.WriteFile(name, append(b, 0), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := newBookText(book, name, "alice").chapter(last); got != s {
+		t.Errorf(expFormat, s, got)
+	}
+}
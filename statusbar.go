@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+// defaultStatus is the status bar's format when the config file does not set
+// one, and defaultPagedStatus its format in paged view.
+const (
+	defaultStatus      = "{chapter}\t{page}"
+	defaultPagedStatus = "{chapter}\t{page}  {screen}"
+)
+
+// placeholder matches the placeholders in a status bar format.
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// status fills in the placeholders of the status bar's format. Text after a
+// tab is right aligned. Unknown placeholders are left as they are.
+func (a *app) status() (string, string) {
+	fields := map[string]func() string{
+		"book":     func() string { return a.book.Title },
+		"chapter":  a.title,
+		"item":     func() string { return strconv.Itoa(a.chapter + 1) },
+		"total":    func() string { return strconv.Itoa(len(a.book.Spine.Itemrefs)) },
+		"percent":  func() string { return fmt.Sprintf("%.0f", a.progress()) },
+		"page":     a.printPage,
+		"screen":   a.screen,
+		"left":     a.chapterLeft,
+		"bookleft": a.bookLeft,
+	}
+	format := a.config.Status
+	if a.settings.Paged && format == defaultStatus {
+		format = defaultPagedStatus
+	}
+	text := placeholder.ReplaceAllStringFunc(format, func(s string) string {
+		if f, ok := fields[s[1:len(s)-1]]; ok {
+			return f()
+		}
+		return s
+	})
+
+	left, right, _ := strings.Cut(text, "\t")
+	return left, strings.TrimSpace(right)
+}
+
+// drawStatus displays text in the status bar at the bottom of the terminal,
+// truncating it to fit, and right aligned text after it.
+func drawStatus(text, right string) {
+	width, height := termbox.Size()
+	if height < statusBarHeight {
+		return
+	}
+
+	y := height - statusBarHeight
+	fg := termbox.ColorDefault | termbox.AttrReverse
+	runes := []rune(text)
+	rightRunes := []rune(right)
+	rightStart := width
+	if len(rightRunes) > 0 {
+		rightStart = width - len(rightRunes) - 1
+	}
+	for x := 0; x < width; x++ {
+		ch := ' '
+		if x >= rightStart && x-rightStart < len(rightRunes) {
+			ch = rightRunes[x-rightStart]
+		} else if x > 0 && x-1 < len(runes) && x < rightStart-1 {
+			ch = runes[x-1]
+		}
+		termbox.SetCell(x, y, ch, fg, termbox.ColorDefault)
+	}
+}
+
+// printPage returns the print edition page at the top of the pager, if the
+// current chapter marks its pages.
+func (a *app) printPage() string {
+	p, ok := a.pager.printPage()
+	if !ok {
+		return ""
+	}
+
+	return "Page " + p.Label
+}
+
+// screen returns the number of the screen being read in the current chapter,
+// e.g. "3/12".
+func (a *app) screen() string {
+	n, total := a.pager.screen()
+	return fmt.Sprintf("%d/%d", n, total)
+}
+
+// chapterLeft returns how much of the current chapter is left from the top of
+// the pager on, in lines of text and in minutes of reading at the configured
+// speed, e.g. "40 lines / ~3 min left".
+func (a *app) chapterLeft() string {
+	lines, words := a.pager.remaining()
+	wpm := a.config.WordsPerMinute
+	if wpm <= 0 {
+		wpm = defaultWordsPerMinute
+	}
+
+	return fmt.Sprintf("%s / ~%d min left", count(lines, "line"), (words+wpm-1)/wpm)
+}
+
+// bookLeft returns the time it takes to read the rest of the book from the
+// top of the pager on, at the configured speed, e.g. "~2 h 5 min left in
+// book". Chapters outside the main reading order, such as notes, are not
+// counted.
+func (a *app) bookLeft() string {
+	_, words := a.pager.remaining()
+	for i := a.chapter + 1; i < len(a.book.Spine.Itemrefs); i++ {
+		if a.book.Spine.Itemrefs[i].Linear != "no" {
+			words += a.text.wordCount(i)
+		}
+	}
+	wpm := a.config.WordsPerMinute
+	if wpm <= 0 {
+		wpm = defaultWordsPerMinute
+	}
+
+	minutes := (words + wpm - 1) / wpm
+	if minutes < 60 {
+		return fmt.Sprintf("~%d min left in book", minutes)
+	}
+	return fmt.Sprintf("~%d h %d min left in book", minutes/60, minutes%60)
+}
+
+// title returns the title of the chapter, or section, currently being read.
+// Titles are taken from headings; when none are available the position of the
+// chapter in the spine is used instead.
+func (a *app) title() string {
+	if a.settings.Split > 0 {
+		if h, ok := a.pager.sectionHeading(a.settings.Split); ok && h.Title != "" {
+			return h.Title
+		}
+	}
+
+	if h, ok := a.pager.titleHeading(); ok && h.Title != "" {
+		return h.Title
+	}
+
+	return fmt.Sprintf("Chapter %d of %d", a.chapter+1, len(a.book.Spine.Itemrefs))
+}
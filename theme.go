@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	termbox "github.com/nsf/termbox-go"
+	"github.com/taylorskalyo/goreader/render"
+)
+
+// themeExt is the extension of theme files, which are named after the theme
+// they define.
+const themeExt = ".json"
+
+// themesDir returns the directory theme files are read from.
+func themesDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "themes"), nil
+}
+
+// themeNames returns the names of the built-in themes, followed by those of
+// the themes defined in theme files.
+func themeNames() []string {
+	var names []string
+	for _, t := range render.Themes {
+		names = append(names, t.Name)
+	}
+
+	dir, err := themesDir()
+	if err != nil {
+		return names
+	}
+	files, _ := os.ReadDir(dir)
+	for _, f := range files {
+		name, ok := strings.CutSuffix(f.Name(), themeExt)
+		if ok && !f.IsDir() && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// loadTheme returns the theme with the given name: the one defined in the
+// theme file of that name, if there is one, or else the built-in theme. An
+// empty name selects the default theme.
+func loadTheme(name string) (render.Theme, error) {
+	if name == "" {
+		t, _ := render.LookupTheme(name)
+		return t, nil
+	}
+
+	if dir, err := themesDir(); err == nil {
+		b, err := os.ReadFile(filepath.Join(dir, name+themeExt))
+		if err == nil {
+			t, err := parseTheme(name, b)
+			if err != nil {
+				return t, fmt.Errorf("theme %s: %w", name, err)
+			}
+			return t, nil
+		} else if !os.IsNotExist(err) {
+			return render.Theme{}, err
+		}
+	}
+
+	t, ok := render.LookupTheme(name)
+	if !ok {
+		return t, fmt.Errorf("unknown theme %q", name)
+	}
+
+	return t, nil
+}
+
+// parseTheme parses a theme file, which maps roles to attributes (see
+// render.ParseAttribute), e.g. {"italic": "yellow", "h1": "#ff8000 bold"}.
+// "headings" sets the attribute of every heading level, which "h1" to "h6"
+// override. Roles the file leaves out take their attribute from the default
+// theme.
+func parseTheme(name string, b []byte) (render.Theme, error) {
+	t, _ := render.LookupTheme("")
+	t.Name = name
+
+	var roles map[string]string
+	if err := json.Unmarshal(b, &roles); err != nil {
+		return t, err
+	}
+
+	fields := map[string]*termbox.Attribute{
+		"body":       &t.Body,
+		"background": &t.Background,
+		"bold":       &t.Bold,
+		"italic":     &t.Italic,
+		"title":      &t.Title,
+		"link":       &t.Link,
+		"highlight":  &t.Highlight,
+		"quote":      &t.Quote,
+
+		"search_match":   &t.SearchMatch,
+		"search_current": &t.SearchCurrent,
+		"selected_link":  &t.SelectedLink,
+		"visited_link":   &t.VisitedLink,
+	}
+	for i := range t.Headings {
+		fields[fmt.Sprintf("h%d", i+1)] = &t.Headings[i]
+	}
+
+	if s, ok := roles["headings"]; ok {
+		attr, err := render.ParseAttribute(s)
+		if err != nil {
+			return t, fmt.Errorf("headings: %w", err)
+		}
+		for i := range t.Headings {
+			t.Headings[i] = attr
+		}
+	}
+	for role, s := range roles {
+		if role == "headings" {
+			continue
+		}
+		field, ok := fields[role]
+		if !ok {
+			return t, fmt.Errorf("unknown role %q", role)
+		}
+		attr, err := render.ParseAttribute(s)
+		if err != nil {
+			return t, fmt.Errorf("%s: %w", role, err)
+		}
+		*field = attr
+	}
+
+	return t, nil
+}
+
+// setTheme loads the theme named in the settings, without its colors when
+// they are turned off. The default theme is used if it cannot be loaded, and
+// the reason shown in the status bar.
+func (a *app) setTheme() {
+	t, err := loadTheme(a.settings.Theme)
+	if err != nil {
+		a.message = fmt.Sprintf("Unable to load theme: %s", err)
+		t, _ = render.LookupTheme("")
+	}
+	if a.config.NoColor {
+		t = t.Monochrome()
+	}
+	a.theme = t
+	a.pager.bg, a.pager.theme = t.Background, t
+}
+
+// themeMenu switches to a theme chosen from the built-in themes and those
+// defined in theme files.
+func (a *app) themeMenu() error {
+	m := &menu{title: "Themes", entries: themeNames(), markCurrent: true}
+	m.current = max(slices.Index(m.entries, a.theme.Name), 0)
+	m.selected = m.current
+
+	i, err := a.runMenu(m)
+	if err != nil || i < 0 {
+		return err
+	}
+
+	a.settings.Theme = m.entries[i]
+	a.setTheme()
+
+	return a.reflow()
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	termbox "github.com/nsf/termbox-go"
+)
+
+func TestParseTheme(t *testing.T) {
+	t.Run("Roles", func(t *testing.T) {
+		th, err := parseTheme("paper", []byte(`{"body": "black", "headings": "blue", "h1": "red bold", "link": "#0000ff underline", "search_current": "red reverse"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if th.Name != "paper" {
+			t.Errorf(expFormat, "paper", th.Name)
+		}
+		if th.Body != termbox.ColorBlack {
+			t.Errorf(expFormat, termbox.ColorBlack, th.Body)
+		}
+		if exp := termbox.ColorRed | termbox.AttrBold; th.Headings[0] != exp {
+			t.Errorf(expFormat, exp, th.Headings[0])
+		}
+		if th.Headings[5] != termbox.ColorBlue {
+			t.Errorf(expFormat, termbox.ColorBlue, th.Headings[5])
+		}
+		if exp := termbox.ColorBlue | termbox.AttrUnderline; th.Link != exp {
+			t.Errorf(expFormat, exp, th.Link)
+		}
+		if exp := termbox.ColorRed | termbox.AttrReverse; th.SearchCurrent != exp {
+			t.Errorf(expFormat, exp, th.SearchCurrent)
+		}
+
+		// Roles left out are those of the default theme.
+		if th.Bold != termbox.AttrBold || th.Title != termbox.ColorRed {
+			t.Errorf(expFormat, "default bold and title", th)
+		}
+	})
+
+	for name, src := range map[string]string{
+		"UnknownRole":  `{"sidebar": "green"}`,
+		"UnknownColor": `{"body": "grey"}`,
+		"Malformed":    `{"body": }`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parseTheme("bad", []byte(src)); err == nil {
+				t.Errorf(expFormat, "an error", err)
+			}
+		})
+	}
+}
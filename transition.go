@@ -0,0 +1,50 @@
+package main
+
+import (
+	termbox "github.com/nsf/termbox-go"
+	"github.com/taylorskalyo/goreader/render"
+)
+
+// contextLines is the number of lines of the previous chapter kept in view
+// by the overlap transition.
+const contextLines = 3
+
+// transition shows the move from the chapter prev to the newly opened one,
+// as set by chapter_transition. The last lines of prev are kept above the new
+// chapter until it is next opened, but only when moving forward to its top
+// outside paged view, where screens are whole pages of the chapter. An
+// interstitial lasts until the next key press.
+func (a *app) transition(prev render.Document, forward bool) {
+	switch a.config.ChapterTransition {
+	case transitionOverlap:
+		if forward && a.pager.scrollY == 0 && !a.settings.Paged {
+			a.pager.lead = lastLines(prev, contextLines)
+		}
+	case transitionInterstitial:
+		a.interstitial = "— " + a.title() + " —"
+	}
+}
+
+// lastLines returns the cells of the last n rows of doc that hold text.
+func lastLines(doc render.Document, n int) [][]termbox.Cell {
+	var rows [][]termbox.Cell
+	for y := doc.Rows() - 1; y >= 0 && len(rows) < n; y-- {
+		row := doc.Cells[y*doc.Width : min((y+1)*doc.Width, len(doc.Cells))]
+		for _, cell := range row {
+			if cell.Ch != 0 && cell.Ch != ' ' {
+				rows = append([][]termbox.Cell{row}, rows...)
+				break
+			}
+		}
+	}
+
+	return rows
+}
+
+// drawInterstitial displays the interstitial shown in place of the pager on
+// moving to another chapter, in the middle of the viewport.
+func (a *app) drawInterstitial() {
+	width, height := viewSize()
+	n := len([]rune(a.interstitial))
+	printText(max((width-n)/2, 0), height/2, width, a.interstitial, termbox.ColorDefault|termbox.AttrBold, a.theme.Background)
+}
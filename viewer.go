@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/taylorskalyo/goreader/epub"
+)
+
+// thumbnailHint follows images rendered as thumbnails, which can be seen in
+// full by opening them in the viewer.
+const thumbnailHint = "[press o to view]"
+
+// openResource opens the first image, audio or video file shown within the
+// pager's viewport in an external viewer, since the terminal can only
+// approximate them.
+func (a *app) openResource() {
+	_, viewHeight := viewSize()
+	for _, r := range a.pager.doc.Resources {
+		if r.End < a.pager.scrollY || r.Row >= a.pager.scrollY+viewHeight {
+			continue
+		}
+		if err := view(r.Item, a.config.Viewer); err != nil {
+			a.message = fmt.Sprintf("Unable to open %s: %s", path.Base(r.Item.HREF), err)
+		}
+		return
+	}
+
+	a.message = "No image or media on screen"
+}
+
+// view extracts an item to a temporary file and opens it with command, or the
+// system's default application when command is empty. The file is left for
+// the viewer to read; it is not removed.
+func view(item epub.Item, command string) error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		args = defaultViewer()
+	}
+
+	rc, err := item.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := os.CreateTemp("", "goreader-*"+path.Ext(item.HREF))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// The viewer runs independently of the reader, with its output discarded
+	// so that it does not disturb the display.
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	if err := cmd.Start(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	go cmd.Wait()
+
+	return nil
+}
+
+// defaultViewer returns the command that opens a file with the system's
+// default application.
+func defaultViewer() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		return []string{"open"}
+	case "windows":
+		return []string{"rundll32", "url.dll,FileProtocolHandler"}
+	}
+
+	return []string{"xdg-open"}
+}